@@ -8,6 +8,11 @@ import (
 	"net/http"
 )
 
+const (
+	ActionViewDetail = "detail"
+	ActionViewList   = "list"
+)
+
 var ErrActionMissingAction = errors.New("missing action or entity")
 
 type ActionHandlerNotFoundError struct {
@@ -21,6 +26,36 @@ func (e ActionHandlerNotFoundError) Error() string {
 
 type ActionHandler func(ctx context.Context, action ActionRequest, api *APIClient) error
 
+type actionKey struct {
+	entity string
+	action string
+}
+
+// actionButton is a registered action handler with the details of its button in the administration.
+type actionButton struct {
+	handler ActionHandler
+	view    string
+	label   string
+}
+
+type ActionOpt func(b *actionButton)
+
+// WithActionView sets the view of the administration, which shows the action button, e.g. ActionViewList. Defaults
+// to ActionViewDetail. It's used to generate the manifest.xml.
+func WithActionView(view string) ActionOpt {
+	return func(b *actionButton) {
+		b.view = view
+	}
+}
+
+// WithActionLabel sets the label of the action button. Defaults to the name of the action. It's used to generate the
+// manifest.xml.
+func WithActionLabel(label string) ActionOpt {
+	return func(b *actionButton) {
+		b.label = label
+	}
+}
+
 type ActionRequest struct {
 	*AppRequest
 
@@ -38,7 +73,7 @@ type ActionRequest struct {
 }
 
 func (srv *Server) HandleAction(req *http.Request) error {
-	if err := srv.verifyPayloadSignature(req); err != nil {
+	if _, err := srv.verifyPayloadSignature(req); err != nil {
 		return err
 	}
 
@@ -57,7 +92,9 @@ func (srv *Server) HandleAction(req *http.Request) error {
 		return ErrActionMissingAction
 	}
 
-	h, ok := srv.actions[actionReq.Data.Entity+actionReq.Data.Action]
+	actionReq.ShopwareVersion = req.Header.Get(ShopwareVersionHeader)
+
+	b, ok := srv.actions[actionKey{entity: actionReq.Data.Entity, action: actionReq.Data.Action}]
 	if !ok {
 		return ActionHandlerNotFoundError{entity: actionReq.Data.Entity, action: actionReq.Data.Action}
 	}
@@ -67,10 +104,30 @@ func (srv *Server) HandleAction(req *http.Request) error {
 		return fmt.Errorf("get shop credentials: %w", err)
 	}
 
-	err = h(req.Context(), actionReq, newAPIClient(srv.httpClient, srv.appName, credentials, srv.tokenStore))
+	api := srv.newAPIClient(credentials)
+	if actionReq.Meta.LanguageID != "" {
+		// use the language of the admin user, who triggered the action
+		api = api.With(RequestLanguageID(actionReq.Meta.LanguageID))
+	}
+
+	inv := Invocation{
+		Type:   InvocationTypeAction,
+		Name:   actionReq.Data.Action,
+		Entity: actionReq.Data.Entity,
+		Source: actionReq.Source,
+		API:    api,
+	}
+
+	err = srv.invoke(req.Context(), inv, func(ctx context.Context, inv Invocation) error {
+		return b.handler(ctx, actionReq, inv.API)
+	})
 	if err != nil {
 		return fmt.Errorf("handler: %w", err)
 	}
 
 	return nil
 }
+
+func (k actionKey) String() string {
+	return k.entity + "/" + k.action
+}
@@ -0,0 +1,113 @@
+package appserver
+
+// Cart is the storefront cart as sent by Shopware to payment validation, tax providers and the checkout gateway.
+type Cart struct {
+	Token      string                 `json:"token"`
+	Price      CartPrice              `json:"price"`
+	LineItems  []CartLineItem         `json:"lineItems"`
+	Deliveries []CartDelivery         `json:"deliveries"`
+	CustomerID string                 `json:"customerId"`
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+type CartPrice struct {
+	NetPrice        float64         `json:"netPrice"`
+	TotalPrice      float64         `json:"totalPrice"`
+	PositionPrice   float64         `json:"positionPrice"`
+	RawTotal        float64         `json:"rawTotal"`
+	TaxStatus       string          `json:"taxStatus"`
+	CalculatedTaxes []CalculatedTax `json:"calculatedTaxes"`
+	TaxRules        []TaxRule       `json:"taxRules"`
+}
+
+type CartLineItem struct {
+	ID           string                 `json:"id"`
+	ReferencedID string                 `json:"referencedId"`
+	Type         string                 `json:"type"`
+	Label        string                 `json:"label"`
+	Quantity     int                    `json:"quantity"`
+	Good         bool                   `json:"good"`
+	Price        *CalculatedPrice       `json:"price"`
+	Payload      map[string]interface{} `json:"payload"`
+	Children     []CartLineItem         `json:"children"`
+}
+
+type CartDelivery struct {
+	ShippingCosts  CalculatedPrice        `json:"shippingCosts"`
+	ShippingMethod *ShippingMethod        `json:"shippingMethod"`
+	Positions      []CartDeliveryPosition `json:"positions"`
+}
+
+type CartDeliveryPosition struct {
+	Identifier string          `json:"identifier"`
+	Quantity   int             `json:"quantity"`
+	Price      CalculatedPrice `json:"price"`
+}
+
+type CalculatedPrice struct {
+	UnitPrice       float64         `json:"unitPrice"`
+	TotalPrice      float64         `json:"totalPrice"`
+	Quantity        int             `json:"quantity"`
+	CalculatedTaxes []CalculatedTax `json:"calculatedTaxes"`
+	TaxRules        []TaxRule       `json:"taxRules"`
+}
+
+type CalculatedTax struct {
+	Tax     float64 `json:"tax"`
+	TaxRate float64 `json:"taxRate"`
+	Price   float64 `json:"price"`
+}
+
+type TaxRule struct {
+	TaxRate    float64 `json:"taxRate"`
+	Percentage float64 `json:"percentage"`
+}
+
+// SalesChannelContext describes the storefront session, in which a cart is calculated.
+type SalesChannelContext struct {
+	Token            string                `json:"token"`
+	TaxState         string                `json:"taxState"`
+	Currency         Currency              `json:"currency"`
+	SalesChannel     SalesChannel          `json:"salesChannel"`
+	Customer         *Customer             `json:"customer"`
+	PaymentMethod    PaymentMethodEntity   `json:"paymentMethod"`
+	ShippingMethod   ShippingMethod        `json:"shippingMethod"`
+	ShippingLocation ShippingLocation      `json:"shippingLocation"`
+	Context          SalesChannelContextID `json:"context"`
+}
+
+// SalesChannelContextID contains the IDs of the context a sales channel context was created with.
+type SalesChannelContextID struct {
+	LanguageIDChain []string `json:"languageIdChain"`
+	CurrencyID      string   `json:"currencyId"`
+	VersionID       string   `json:"versionId"`
+}
+
+type Currency struct {
+	ID          string  `json:"id"`
+	IsoCode     string  `json:"isoCode"`
+	Factor      float64 `json:"factor"`
+	Symbol      string  `json:"symbol"`
+	TaxFreeFrom float64 `json:"taxFreeFrom"`
+}
+
+type SalesChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type ShippingMethod struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	TechnicalName string `json:"technicalName"`
+}
+
+type ShippingLocation struct {
+	Country Country `json:"country"`
+}
+
+type Country struct {
+	ID   string `json:"id"`
+	Iso  string `json:"iso"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,111 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	InvocationTypeCheckoutGateway = "checkout-gateway"
+
+	CheckoutGatewayCommandRemovePaymentMethod  = "remove-payment-method"
+	CheckoutGatewayCommandRemoveShippingMethod = "remove-shipping-method"
+	CheckoutGatewayCommandAddCartError         = "add-cart-error"
+)
+
+// CartErrorLevel is the severity of a cart error added by the checkout gateway.
+type CartErrorLevel int
+
+const (
+	CartErrorLevelNotice  CartErrorLevel = 0
+	CartErrorLevelWarning CartErrorLevel = 10
+	CartErrorLevelError   CartErrorLevel = 20
+)
+
+var ErrCheckoutGatewayNotRegistered = errors.New("no checkout gateway handler registered")
+
+// CheckoutGatewayHandler decides, which payment and shipping methods are available for a cart.
+type CheckoutGatewayHandler func(ctx context.Context, gateway CheckoutGatewayRequest, api *APIClient) ([]CheckoutGatewayCommand, error)
+
+type CheckoutGatewayRequest struct {
+	*AppRequest
+
+	Cart Cart `json:"cart"`
+	// PaymentMethods are the technical names of the available payment methods.
+	PaymentMethods []string `json:"paymentMethods"`
+	// ShippingMethods are the technical names of the available shipping methods.
+	ShippingMethods     []string            `json:"shippingMethods"`
+	SalesChannelContext SalesChannelContext `json:"salesChannelContext"`
+}
+
+// CheckoutGatewayCommand modifies the checkout. Use the constructors, e.g. RemovePaymentMethod, to create them.
+type CheckoutGatewayCommand struct {
+	Command string                 `json:"command"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// RemovePaymentMethod removes the payment method with the technical name from the checkout.
+func RemovePaymentMethod(technicalName string) CheckoutGatewayCommand {
+	return CheckoutGatewayCommand{
+		Command: CheckoutGatewayCommandRemovePaymentMethod,
+		Payload: map[string]interface{}{"paymentMethodTechnicalName": technicalName},
+	}
+}
+
+// RemoveShippingMethod removes the shipping method with the technical name from the checkout.
+func RemoveShippingMethod(technicalName string) CheckoutGatewayCommand {
+	return CheckoutGatewayCommand{
+		Command: CheckoutGatewayCommandRemoveShippingMethod,
+		Payload: map[string]interface{}{"shippingMethodTechnicalName": technicalName},
+	}
+}
+
+// AddCartError shows an error in the checkout. Blocking errors prevent the customer from placing the order.
+func AddCartError(message string, level CartErrorLevel, blocking bool) CheckoutGatewayCommand {
+	return CheckoutGatewayCommand{
+		Command: CheckoutGatewayCommandAddCartError,
+		Payload: map[string]interface{}{"message": message, "level": level, "blocking": blocking},
+	}
+}
+
+// CheckoutGateway registers the handler for the checkout gateway of the app.
+func (srv *Server) CheckoutGateway(handler CheckoutGatewayHandler) {
+	srv.checkoutGateway = handler
+}
+
+// HandleCheckoutGateway verifies and handles a checkout gateway request of Shopware. The returned response must be
+// written with SignedResponse.Write.
+func (srv *Server) HandleCheckoutGateway(req *http.Request) (SignedResponse, error) {
+	if srv.checkoutGateway == nil {
+		return SignedResponse{}, ErrCheckoutGatewayNotRegistered
+	}
+
+	body, credentials, secret, err := srv.readSignedRequest(req)
+	if err != nil {
+		return SignedResponse{}, err
+	}
+
+	gatewayReq := CheckoutGatewayRequest{}
+	if err := json.Unmarshal(body, &gatewayReq); err != nil {
+		return SignedResponse{}, fmt.Errorf("parse body: %w", err)
+	}
+
+	inv := Invocation{
+		Type:   InvocationTypeCheckoutGateway,
+		Source: gatewayReq.Source,
+		API:    srv.newAPIClient(credentials),
+	}
+
+	return srv.invokeSigned(req.Context(), inv, secret, func(ctx context.Context, api *APIClient) (interface{}, error) {
+		commands, err := srv.checkoutGateway(ctx, gatewayReq, api)
+		if commands == nil {
+			// Shopware expects a list, even without commands
+			commands = []CheckoutGatewayCommand{}
+		}
+
+		return commands, err
+	})
+}
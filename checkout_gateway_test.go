@@ -0,0 +1,50 @@
+package appserver_test
+
+import (
+	"context"
+	"testing"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleCheckoutGateway(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	payload := `{"source":{"shopId":"123"},"cart":{"price":{"totalPrice":1500}},` +
+		`"paymentMethods":["payment_invoice","payment_prepayment"],"shippingMethods":["shipping_standard","shipping_express"]}`
+
+	_, err := srv.HandleCheckoutGateway(appserver.NewSignedTestRequest("mysecret", payload))
+	assert.ErrorIs(t, err, appserver.ErrCheckoutGatewayNotRegistered)
+
+	srv.CheckoutGateway(func(_ context.Context, gateway appserver.CheckoutGatewayRequest, _ *appserver.APIClient) ([]appserver.CheckoutGatewayCommand, error) {
+		assert.Equal(t, []string{"payment_invoice", "payment_prepayment"}, gateway.PaymentMethods)
+		assert.Equal(t, []string{"shipping_standard", "shipping_express"}, gateway.ShippingMethods)
+
+		if gateway.Cart.Price.TotalPrice < 1000 {
+			return nil, nil
+		}
+
+		return []appserver.CheckoutGatewayCommand{
+			appserver.RemovePaymentMethod("payment_invoice"),
+			appserver.RemoveShippingMethod("shipping_express"),
+			appserver.AddCartError("Invoice is not available for orders above 1000 EUR", appserver.CartErrorLevelWarning, false),
+		}, nil
+	})
+
+	resp, err := srv.HandleCheckoutGateway(appserver.NewSignedTestRequest("mysecret", payload))
+	require.NoError(t, err)
+	assertSignedResponse(t, resp, "mysecret", `[
+		{"command":"remove-payment-method","payload":{"paymentMethodTechnicalName":"payment_invoice"}},
+		{"command":"remove-shipping-method","payload":{"shippingMethodTechnicalName":"shipping_express"}},
+		{"command":"add-cart-error","payload":{"message":"Invoice is not available for orders above 1000 EUR","level":10,"blocking":false}}
+	]`)
+
+	payload = `{"source":{"shopId":"123"},"cart":{"price":{"totalPrice":10}},` +
+		`"paymentMethods":["payment_invoice","payment_prepayment"],"shippingMethods":["shipping_standard","shipping_express"]}`
+
+	resp, err = srv.HandleCheckoutGateway(appserver.NewSignedTestRequest("mysecret", payload))
+	require.NoError(t, err)
+	assertSignedResponse(t, resp, "mysecret", `[]`)
+}
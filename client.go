@@ -12,11 +12,56 @@ import (
 	"golang.org/x/oauth2/clientcredentials"
 )
 
+const (
+	HeaderLanguageID      = "sw-language-id"
+	HeaderCurrencyID      = "sw-currency-id"
+	HeaderVersionID       = "sw-version-id"
+	HeaderInheritance     = "sw-inheritance"
+	HeaderSkipTriggerFlow = "sw-skip-trigger-flow"
+)
+
 type APIClient struct {
 	appName     string
 	credentials Credentials
 	tokenStore  *tokenStore
 	httpClient  *http.Client
+	requestOpts []RequestOpt
+	configCache *appConfigCache
+}
+
+// RequestOpt modifies the headers of requests sent by the APIClient.
+type RequestOpt func(header http.Header)
+
+// RequestLanguageID sets the language in which the shop reads and writes translated fields.
+func RequestLanguageID(languageID string) RequestOpt {
+	return RequestHeader(HeaderLanguageID, languageID)
+}
+
+// RequestCurrencyID sets the currency used by the shop to calculate prices.
+func RequestCurrencyID(currencyID string) RequestOpt {
+	return RequestHeader(HeaderCurrencyID, currencyID)
+}
+
+// RequestVersionID lets the request operate on the given entity version instead of the live version.
+func RequestVersionID(versionID string) RequestOpt {
+	return RequestHeader(HeaderVersionID, versionID)
+}
+
+// RequestInheritance makes the shop resolve inherited values, e.g. of product variants, in responses.
+func RequestInheritance() RequestOpt {
+	return RequestHeader(HeaderInheritance, "1")
+}
+
+// RequestSkipTriggerFlow prevents the shop from triggering flows for changes made by the request.
+func RequestSkipTriggerFlow() RequestOpt {
+	return RequestHeader(HeaderSkipTriggerFlow, "1")
+}
+
+// RequestHeader sets an arbitrary header on the request.
+func RequestHeader(key string, value string) RequestOpt {
+	return func(header http.Header) {
+		header.Set(key, value)
+	}
 }
 
 func newAPIClient(httpClient *http.Client, appName string, credentials Credentials, tokenStore *tokenStore) *APIClient {
@@ -28,51 +73,85 @@ func newAPIClient(httpClient *http.Client, appName string, credentials Credentia
 	}
 }
 
-func (c *APIClient) Request(ctx context.Context, method string, path string, payload interface{}) (*http.Response, error) {
-	token, err := c.getTokenForShop(c.credentials.ShopID)
-	if err != nil {
-		return nil, fmt.Errorf("get token: %v", err)
-	}
+// With returns a copy of the client, which applies the given options to every request in addition to the options
+// already set on the client.
+func (c *APIClient) With(opts ...RequestOpt) *APIClient {
+	clone := *c
+	clone.requestOpts = make([]RequestOpt, 0, len(c.requestOpts)+len(opts))
+	clone.requestOpts = append(clone.requestOpts, c.requestOpts...)
+	clone.requestOpts = append(clone.requestOpts, opts...)
+
+	return &clone
+}
 
-	var pdata []byte
+func (c *APIClient) Request(ctx context.Context, method string, path string, payload interface{}, opts ...RequestOpt) (*http.Response, error) {
+	var body io.Reader
 	if payload != nil {
-		pdata, err = json.Marshal(payload)
+		pdata, err := json.Marshal(payload)
 		if err != nil {
 			return nil, fmt.Errorf("encode payload: %v", err)
 		}
+
+		body = bytes.NewReader(pdata)
+	}
+
+	return c.RequestRaw(ctx, method, path, "application/json", body, opts...)
+}
+
+// RequestRaw sends body as-is to the shop's API, using contentType as the content-type header. Use this for endpoints
+// that don't accept JSON, like binary media uploads.
+func (c *APIClient) RequestRaw(ctx context.Context, method string, path string, contentType string, body io.Reader, opts ...RequestOpt) (*http.Response, error) {
+	token, err := c.getTokenForShop(c.credentials.ShopID)
+	if err != nil {
+		return nil, fmt.Errorf("get token: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.credentials.ShopURL+path, bytes.NewReader(pdata))
+	req, err := http.NewRequestWithContext(ctx, method, c.credentials.ShopURL+path, body)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("accept", "application/json")
-	req.Header.Set("content-type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	if contentType != "" {
+		req.Header.Set("content-type", contentType)
+	}
+
+	for _, o := range c.requestOpts {
+		o(req.Header)
+	}
+
+	for _, o := range opts {
+		o(req.Header)
+	}
 
 	return c.httpClient.Do(req)
 }
 
-func (c *APIClient) GetAppConfig(ctx context.Context) (map[string]interface{}, error) {
-	resp, err := c.Request(ctx, http.MethodGet, "/api/_action/system-config?domain="+c.appName+".config", nil)
-	if err != nil {
-		return nil, err
-	}
+// APIResponseError is returned by helpers of the APIClient, if the shop responds with a non-successful status code.
+type APIResponseError struct {
+	StatusCode int
+	Body       []byte
+}
 
-	bodyString, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+func (e APIResponseError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+// checkResponse returns an APIResponseError and closes the body, if the response is not successful.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
 	}
-	resp.Body.Close()
 
-	out := map[string]interface{}{}
-	err = json.Unmarshal(bodyString, &out)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("read body: %w", err)
 	}
 
-	return out, nil
+	return APIResponseError{StatusCode: resp.StatusCode, Body: body}
 }
 
 func (c *APIClient) getTokenForShop(shopID string) (*oauth2.Token, error) {
@@ -0,0 +1,72 @@
+package appserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func newTestAPIClient(t *testing.T, handler http.HandlerFunc) *APIClient {
+	t.Helper()
+
+	shop := httptest.NewServer(handler)
+	t.Cleanup(shop.Close)
+
+	tokens := newTokenStore()
+	tokens.Store("shop", &oauth2.Token{AccessToken: "token"})
+
+	return newAPIClient(shop.Client(), "TestApp", Credentials{ShopID: "shop", ShopURL: shop.URL}, tokens)
+}
+
+func TestAPIClient_RequestRaw(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "text/plain", r.Header.Get("content-type"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	resp, err := client.RequestRaw(context.Background(), http.MethodPost, "/api/foo", "text/plain", strings.NewReader("foo"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestCheckResponse(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	resp, err := client.Request(context.Background(), http.MethodGet, "/api/foo", nil)
+	require.NoError(t, err)
+
+	err = checkResponse(resp)
+	var apiErr APIResponseError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "not found\n", string(apiErr.Body))
+	}
+}
+
+func TestAPIClient_RequestOpts(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "lang", r.Header.Get(HeaderLanguageID))
+		assert.Equal(t, "currency", r.Header.Get(HeaderCurrencyID))
+		assert.Equal(t, "1", r.Header.Get(HeaderInheritance))
+		assert.Equal(t, "", r.Header.Get(HeaderVersionID))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	scoped := client.With(RequestLanguageID("lang"), RequestInheritance())
+	assert.Empty(t, client.requestOpts)
+
+	resp, err := scoped.Request(context.Background(), http.MethodGet, "/api/foo", nil, RequestCurrencyID("currency"))
+	require.NoError(t, err)
+	resp.Body.Close()
+}
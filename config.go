@@ -0,0 +1,173 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GetAppConfig returns the global configuration of the app, keyed by the full config key, e.g. "AppName.config.field".
+func (c *APIClient) GetAppConfig(ctx context.Context) (map[string]interface{}, error) {
+	return c.getSystemConfig(ctx, "")
+}
+
+// GetSalesChannelAppConfig returns the configuration of the app for a sales channel, keyed by the full config key.
+// Values not set for the sales channel fall back to the global configuration, like they do in the storefront.
+func (c *APIClient) GetSalesChannelAppConfig(ctx context.Context, salesChannelID string) (map[string]interface{}, error) {
+	global, err := c.getSystemConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if salesChannelID == "" {
+		return global, nil
+	}
+
+	salesChannel, err := c.getSystemConfig(ctx, salesChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range salesChannel {
+		if value != nil {
+			global[key] = value
+		}
+	}
+
+	return global, nil
+}
+
+// DecodeAppConfig decodes the configuration of the app into v, which is usually a pointer to a struct. The domain
+// prefix is stripped from the config keys, so fields are matched by their json tags with the name of the config
+// field, e.g. `json:"apiToken"`. If salesChannelID is empty, the global configuration is decoded.
+func (c *APIClient) DecodeAppConfig(ctx context.Context, salesChannelID string, v interface{}) error {
+	config, err := c.GetSalesChannelAppConfig(ctx, salesChannelID)
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		fields[strings.TrimPrefix(key, c.appConfigDomain()+".")] = value
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode config: %w", err)
+	}
+
+	return nil
+}
+
+// SetAppConfig writes configuration values of the app. The values are usually a struct or a map with the names of
+// the config fields as keys, the domain prefix is added automatically. If salesChannelID is empty, the global
+// configuration is written.
+func (c *APIClient) SetAppConfig(ctx context.Context, salesChannelID string, values interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+
+	config := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		config[c.appConfigDomain()+"."+key] = value
+	}
+
+	path := "/api/_action/system-config"
+	if salesChannelID != "" {
+		path += "?salesChannelId=" + url.QueryEscape(salesChannelID)
+	}
+
+	resp, err := c.Request(ctx, http.MethodPost, path, config)
+	if err != nil {
+		return err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	c.InvalidateAppConfig()
+
+	return nil
+}
+
+// InvalidateAppConfig removes the cached app configuration of the shop, so the next read fetches it from the shop.
+// This has no effect, if the server doesn't use WithAppConfigCache.
+func (c *APIClient) InvalidateAppConfig() {
+	if c.configCache != nil {
+		c.configCache.Invalidate(c.credentials.ShopID)
+	}
+}
+
+func (c *APIClient) getSystemConfig(ctx context.Context, salesChannelID string) (map[string]interface{}, error) {
+	if c.configCache != nil {
+		if config, ok := c.configCache.Get(c.credentials.ShopID, salesChannelID); ok {
+			return config, nil
+		}
+	}
+
+	config, err := c.fetchSystemConfig(ctx, salesChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.configCache != nil {
+		c.configCache.Store(c.credentials.ShopID, salesChannelID, config)
+	}
+
+	return config, nil
+}
+
+func (c *APIClient) fetchSystemConfig(ctx context.Context, salesChannelID string) (map[string]interface{}, error) {
+	query := url.Values{}
+	query.Set("domain", c.appConfigDomain())
+	if salesChannelID != "" {
+		query.Set("salesChannelId", salesChannelID)
+	}
+
+	resp, err := c.Request(ctx, http.MethodGet, "/api/_action/system-config?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{}
+
+	// Shopware returns an empty list instead of an object, if nothing is configured
+	if string(body) == "[]" {
+		return out, nil
+	}
+
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (c *APIClient) appConfigDomain() string {
+	return c.appName + ".config"
+}
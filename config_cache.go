@@ -0,0 +1,72 @@
+package appserver
+
+import (
+	"sync"
+	"time"
+)
+
+// appConfigCache caches the app configuration per shop and sales channel, see WithAppConfigCache.
+type appConfigCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	entries   map[string]map[string]appConfigCacheEntry
+	entriesMu sync.RWMutex
+}
+
+type appConfigCacheEntry struct {
+	config    map[string]interface{}
+	expiresAt time.Time
+}
+
+func newAppConfigCache(ttl time.Duration) *appConfigCache {
+	return &appConfigCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]map[string]appConfigCacheEntry),
+	}
+}
+
+func (c *appConfigCache) Get(shopID string, salesChannelID string) (map[string]interface{}, bool) {
+	c.entriesMu.RLock()
+	defer c.entriesMu.RUnlock()
+
+	entry, ok := c.entries[shopID][salesChannelID]
+	if !ok || c.now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return copyConfig(entry.config), true
+}
+
+func (c *appConfigCache) Store(shopID string, salesChannelID string, config map[string]interface{}) {
+	c.entriesMu.Lock()
+	defer c.entriesMu.Unlock()
+
+	if _, ok := c.entries[shopID]; !ok {
+		c.entries[shopID] = make(map[string]appConfigCacheEntry)
+	}
+
+	c.entries[shopID][salesChannelID] = appConfigCacheEntry{
+		config:    copyConfig(config),
+		expiresAt: c.now().Add(c.ttl),
+	}
+}
+
+// Invalidate removes the configuration of all sales channels of a shop.
+func (c *appConfigCache) Invalidate(shopID string) {
+	c.entriesMu.Lock()
+	defer c.entriesMu.Unlock()
+
+	delete(c.entries, shopID)
+}
+
+// copyConfig creates a shallow copy, so callers can't modify cached entries.
+func copyConfig(config map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		out[key] = value
+	}
+
+	return out
+}
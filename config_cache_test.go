@@ -0,0 +1,82 @@
+package appserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestAppConfigCache(t *testing.T) {
+	now := time.Now()
+	cache := newAppConfigCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.Store("shopA", "", map[string]interface{}{"foo": "bar"})
+
+	config, ok := cache.Get("shopA", "")
+	if assert.True(t, ok) {
+		assert.Equal(t, map[string]interface{}{"foo": "bar"}, config)
+	}
+
+	// modifying the returned config must not change the cache
+	config["foo"] = "baz"
+	config, _ = cache.Get("shopA", "")
+	assert.Equal(t, "bar", config["foo"])
+
+	_, ok = cache.Get("shopA", "salesChannel")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.Get("shopA", "")
+	assert.False(t, ok)
+
+	cache.Store("shopA", "", map[string]interface{}{"foo": "bar"})
+	cache.Invalidate("shopA")
+	_, ok = cache.Get("shopA", "")
+	assert.False(t, ok)
+}
+
+func TestServer_AppConfigCacheInvalidation(t *testing.T) {
+	requests := 0
+	shop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"TestApp.config.foo":"bar"}`))
+	}))
+	defer shop.Close()
+
+	store := NewMemoryCredentialStore()
+	require.NoError(t, store.Store(context.Background(), Credentials{ShopID: "123", ShopURL: shop.URL, ShopSecret: "mysecret"}))
+
+	srv := NewServer("TestApp", "mysecret", "", WithCredentialStore(store), WithAppConfigCache(time.Minute))
+	srv.tokenStore.Store("123", &oauth2.Token{AccessToken: "token"})
+
+	credentials, err := store.Get(context.Background(), "123")
+	require.NoError(t, err)
+	api := srv.newAPIClient(credentials)
+
+	for i := 0; i < 3; i++ {
+		config, err := api.GetAppConfig(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "bar", config["TestApp.config.foo"])
+	}
+	assert.Equal(t, 1, requests)
+
+	// the payload of entity written events, as sent for system-config.written, has no config keys, so every write
+	// drops the cache, even if it belongs to another config domain
+	for i, primaryKey := range []string{"0190b3f4e0c37e0fa4f1b6a8d2c9e5a1", "0190b3f4e0c37e0fa4f1b6a8d2c9e5a2"} {
+		payload := `{"data":{"event":"system-config.written","payload":[{"entity":"system_config","operation":"update",` +
+			`"primaryKey":"` + primaryKey + `","updatedFields":["configurationValue","updatedAt"],` +
+			`"versionId":"0fa91ce3e96a4bc2be4bd9ce752c3425"}]},"source":{"shopId":"123"}}`
+		require.NoError(t, srv.HandleWebhook(NewSignedTestRequest("mysecret", payload)))
+
+		_, err = api.GetAppConfig(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2+i, requests)
+	}
+}
@@ -0,0 +1,69 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIClient_DecodeAppConfig(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "TestApp.config", r.URL.Query().Get("domain"))
+
+		switch r.URL.Query().Get("salesChannelId") {
+		case "":
+			_, _ = w.Write([]byte(`{"TestApp.config.apiToken":"global","TestApp.config.limit":10,"TestApp.config.enabled":true}`))
+		case "sc1":
+			_, _ = w.Write([]byte(`{"TestApp.config.apiToken":"sales-channel","TestApp.config.limit":null}`))
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	})
+
+	type config struct {
+		APIToken string `json:"apiToken"`
+		Limit    int    `json:"limit"`
+		Enabled  bool   `json:"enabled"`
+	}
+
+	t.Run("global", func(t *testing.T) {
+		cfg := config{}
+		require.NoError(t, client.DecodeAppConfig(context.Background(), "", &cfg))
+		assert.Equal(t, config{APIToken: "global", Limit: 10, Enabled: true}, cfg)
+	})
+
+	t.Run("sales channel", func(t *testing.T) {
+		cfg := config{}
+		require.NoError(t, client.DecodeAppConfig(context.Background(), "sc1", &cfg))
+		assert.Equal(t, config{APIToken: "sales-channel", Limit: 10, Enabled: true}, cfg)
+	})
+
+	t.Run("sales channel without config", func(t *testing.T) {
+		cfg := config{}
+		require.NoError(t, client.DecodeAppConfig(context.Background(), "sc2", &cfg))
+		assert.Equal(t, config{APIToken: "global", Limit: 10, Enabled: true}, cfg)
+	})
+}
+
+func TestAPIClient_SetAppConfig(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/_action/system-config", r.URL.Path)
+		assert.Equal(t, "sc1", r.URL.Query().Get("salesChannelId"))
+
+		payload := map[string]interface{}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, map[string]interface{}{"TestApp.config.apiToken": "foo"}, payload)
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.SetAppConfig(context.Background(), "sc1", struct {
+		APIToken string `json:"apiToken"`
+	}{APIToken: "foo"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,37 @@
+package appserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// The helpers in this file sign test fixtures. They are exported, so the tests of package appserver_test can use
+// them as well.
+
+// SignTestData signs data with the secret, as Shopware and the app server do for all signatures.
+func SignTestData(secret string, data string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// NewSignedTestRequest creates a request of the shop, e.g. a webhook, with the payload signed by the shop secret.
+func NewSignedTestRequest(secret string, payload string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+	req.Header.Set(ShopSignatureKey, hex.EncodeToString(SignTestData(secret, payload)))
+
+	return req
+}
+
+// SignTestQuery appends the signature of the query, as Shopware does for the URLs of admin modules.
+func SignTestQuery(secret string, query string) string {
+	return query + "&" + ShopSignatureKey + "=" + hex.EncodeToString(SignTestData(secret, query))
+}
+
+// SessionKey derives the key session tokens are signed with from the shop secret.
+var SessionKey = sessionKey
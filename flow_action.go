@@ -0,0 +1,179 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const InvocationTypeFlowAction = "flow-action"
+
+// Types of flow action config fields, see FlowActionConfigField.
+const (
+	FlowActionFieldText         = "text"
+	FlowActionFieldTextArea     = "textarea"
+	FlowActionFieldTextEditor   = "text-editor"
+	FlowActionFieldURL          = "url"
+	FlowActionFieldPassword     = "password"
+	FlowActionFieldInt          = "int"
+	FlowActionFieldFloat        = "float"
+	FlowActionFieldBool         = "bool"
+	FlowActionFieldCheckbox     = "checkbox"
+	FlowActionFieldDatetime     = "datetime"
+	FlowActionFieldDate         = "date"
+	FlowActionFieldTime         = "time"
+	FlowActionFieldColorPicker  = "colorpicker"
+	FlowActionFieldSingleSelect = "single-select"
+	FlowActionFieldMultiSelect  = "multi-select"
+)
+
+var ErrFlowActionMissingName = errors.New("missing flow action name")
+
+type FlowActionNotFoundError struct {
+	name string
+}
+
+func (e FlowActionNotFoundError) Error() string {
+	return fmt.Sprintf("no flow action handler found for name: %s", e.name)
+}
+
+// FlowActionHandler handles a flow action triggered by the Flow Builder.
+type FlowActionHandler func(ctx context.Context, action FlowActionRequest, api *APIClient) error
+
+// TypedFlowActionHandler is a flow action handler, which receives the payload decoded into T.
+type TypedFlowActionHandler[T any] func(ctx context.Context, action FlowActionRequest, payload T, api *APIClient) error
+
+// FlowActionRequest is sent by Shopware when a flow action of the app is executed. The payload contains the
+// parameters of the flow action as configured in the flow-action.xml, rendered with the config of the flow action and
+// the triggering event, e.g. {"orderNumber": "{{ order.orderNumber }}", "tag": "{{ tag }}"}.
+type FlowActionRequest struct {
+	WebhookRequest
+}
+
+// Name returns the name of the flow action.
+func (r FlowActionRequest) Name() string {
+	return r.Data.Event
+}
+
+// FlowActionConfigField describes a field, which the merchant fills in when adding the flow action to a flow.
+type FlowActionConfigField struct {
+	Name string
+	// Type is one of the FlowActionField constants, e.g. FlowActionFieldText.
+	Type         string
+	Label        string
+	HelpText     string
+	Required     bool
+	DefaultValue string
+	// Options are the values to choose from for select fields.
+	Options []string
+}
+
+type flowAction struct {
+	handler      FlowActionHandler
+	label        string
+	requirements []string
+	parameters   map[string]string
+	config       []FlowActionConfigField
+}
+
+type FlowActionOpt func(a *flowAction)
+
+// WithFlowActionLabel sets the label of the flow action in the Flow Builder. Defaults to the name of the flow action.
+func WithFlowActionLabel(label string) FlowActionOpt {
+	return func(a *flowAction) {
+		a.label = label
+	}
+}
+
+// WithFlowActionRequirements sets the data the triggering event has to provide, e.g. "orderAware". The flow-action.xml
+// schema requires at least one requirement, see Server.GenerateFlowActions.
+func WithFlowActionRequirements(requirements ...string) FlowActionOpt {
+	return func(a *flowAction) {
+		a.requirements = append(a.requirements, requirements...)
+	}
+}
+
+// WithFlowActionParameter adds a parameter to the payload, rendered from the triggering event, e.g.
+// WithFlowActionParameter("orderNumber", "{{ order.orderNumber }}"). Config fields are added as parameters
+// automatically.
+func WithFlowActionParameter(name string, value string) FlowActionOpt {
+	return func(a *flowAction) {
+		if a.parameters == nil {
+			a.parameters = make(map[string]string)
+		}
+
+		a.parameters[name] = value
+	}
+}
+
+// WithFlowActionConfig declares the config fields of the flow action. They are used to generate the flow-action.xml.
+func WithFlowActionConfig(fields ...FlowActionConfigField) FlowActionOpt {
+	return func(a *flowAction) {
+		a.config = append(a.config, fields...)
+	}
+}
+
+// FlowAction registers a handler for the flow action with the name used in the flow-action.xml.
+func (srv *Server) FlowAction(name string, handler FlowActionHandler, opts ...FlowActionOpt) {
+	a := &flowAction{handler: handler}
+	for _, o := range opts {
+		o(a)
+	}
+
+	srv.flowActions[name] = a
+}
+
+// FlowActionTyped registers a handler for a flow action, which receives the payload decoded into T.
+func FlowActionTyped[T any](srv *Server, name string, handler TypedFlowActionHandler[T], opts ...FlowActionOpt) {
+	srv.FlowAction(name, func(ctx context.Context, action FlowActionRequest, api *APIClient) error {
+		var payload T
+		if err := action.DecodePayload(&payload); err != nil {
+			return err
+		}
+
+		return handler(ctx, action, payload, api)
+	}, opts...)
+}
+
+// HandleFlowAction verifies and handles the execution of a flow action. The flow action is identified by the name in
+// the payload, so all flow actions can share the same URL.
+func (srv *Server) HandleFlowAction(req *http.Request) error {
+	body, credentials, _, err := srv.readSignedRequest(req)
+	if err != nil {
+		return err
+	}
+
+	actionReq := FlowActionRequest{}
+	if err := json.Unmarshal(body, &actionReq); err != nil {
+		return fmt.Errorf("parse body: %w", err)
+	}
+
+	if actionReq.Name() == "" {
+		return ErrFlowActionMissingName
+	}
+
+	a, ok := srv.flowActions[actionReq.Name()]
+	if !ok {
+		return FlowActionNotFoundError{name: actionReq.Name()}
+	}
+
+	actionReq.ShopwareVersion = req.Header.Get(ShopwareVersionHeader)
+
+	inv := Invocation{
+		Type:   InvocationTypeFlowAction,
+		Name:   actionReq.Name(),
+		Source: actionReq.Source,
+		API:    srv.newAPIClient(credentials),
+	}
+
+	err = srv.invoke(req.Context(), inv, func(ctx context.Context, inv Invocation) error {
+		return a.handler(ctx, actionReq, inv.API)
+	})
+	if err != nil {
+		return fmt.Errorf("handler: %w", err)
+	}
+
+	return nil
+}
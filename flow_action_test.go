@@ -0,0 +1,65 @@
+package appserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleFlowAction(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	type tagOrder struct {
+		OrderNumber string `json:"orderNumber"`
+		Tag         string `json:"tag"`
+	}
+
+	var called tagOrder
+	appserver.FlowActionTyped(srv, "tag.order", func(_ context.Context, action appserver.FlowActionRequest, payload tagOrder, api *appserver.APIClient) error {
+		assert.Equal(t, "tag.order", action.Name())
+		assert.Equal(t, "123", action.Source.ShopID)
+		assert.NotNil(t, api)
+
+		called = payload
+
+		return nil
+	}, appserver.WithFlowActionConfig(appserver.FlowActionConfigField{
+		Name:     "tag",
+		Type:     appserver.FlowActionFieldText,
+		Required: true,
+	}))
+
+	err := srv.HandleFlowAction(appserver.NewSignedTestRequest("mysecret",
+		`{"source":{"shopId":"123"},"data":{"event":"tag.order","payload":{"orderNumber":"10001","tag":"vip"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, tagOrder{OrderNumber: "10001", Tag: "vip"}, called)
+}
+
+func TestServer_HandleFlowActionErrors(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	errHandler := errors.New("handler failed")
+	srv.FlowAction("failing", func(_ context.Context, _ appserver.FlowActionRequest, _ *appserver.APIClient) error {
+		return errHandler
+	})
+
+	err := srv.HandleFlowAction(appserver.NewSignedTestRequest("mysecret",
+		`{"source":{"shopId":"123"},"data":{"event":"unknown","payload":{}}}`))
+	assert.ErrorAs(t, err, &appserver.FlowActionNotFoundError{})
+
+	err = srv.HandleFlowAction(appserver.NewSignedTestRequest("mysecret",
+		`{"source":{"shopId":"123"},"data":{"payload":{}}}`))
+	assert.ErrorIs(t, err, appserver.ErrFlowActionMissingName)
+
+	err = srv.HandleFlowAction(appserver.NewSignedTestRequest("mysecret",
+		`{"source":{"shopId":"123"},"data":{"event":"failing","payload":{}}}`))
+	assert.ErrorIs(t, err, errHandler)
+
+	err = srv.HandleFlowAction(appserver.NewSignedTestRequest("wrongsecret",
+		`{"source":{"shopId":"123"},"data":{"event":"failing","payload":{}}}`))
+	assert.Error(t, err)
+}
@@ -4,7 +4,6 @@ go 1.19
 
 require (
 	github.com/stretchr/testify v1.8.1
-	github.com/thanhpk/randstr v1.0.4
 	golang.org/x/net v0.7.0
 	golang.org/x/oauth2 v0.5.0
 )
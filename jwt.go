@@ -0,0 +1,86 @@
+package appserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// jwtToken is a decoded, but not yet verified HS256 JSON Web Token.
+type jwtToken struct {
+	signingInput []byte
+	signature    []byte
+	claims       []byte
+}
+
+// signJWT creates a HS256 JSON Web Token with the claims.
+func signJWT(claims interface{}, key string) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encode claims: %w", err)
+	}
+
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseJWT decodes a HS256 JSON Web Token. The signature has to be verified with the signing input afterwards.
+func parseJWT(token string) (jwtToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtToken{}, errors.New("malformed token")
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return jwtToken{}, fmt.Errorf("decode header: %w", err)
+	}
+
+	alg := struct {
+		Alg string `json:"alg"`
+	}{}
+	if err := json.Unmarshal(header, &alg); err != nil {
+		return jwtToken{}, fmt.Errorf("parse header: %w", err)
+	}
+
+	// only HMAC tokens are signed with the shop secret, never accept anything else
+	if alg.Alg != "HS256" {
+		return jwtToken{}, fmt.Errorf("unsupported algorithm: %s", alg.Alg)
+	}
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return jwtToken{}, fmt.Errorf("decode claims: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtToken{}, fmt.Errorf("decode signature: %w", err)
+	}
+
+	return jwtToken{
+		signingInput: []byte(parts[0] + "." + parts[1]),
+		signature:    signature,
+		claims:       bytes.TrimSpace(claims),
+	}, nil
+}
+
+// decodeClaims decodes the claims of the token into v.
+func (t jwtToken) decodeClaims(v interface{}) error {
+	if err := json.Unmarshal(t.claims, v); err != nil {
+		return fmt.Errorf("parse claims: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,620 @@
+package appserver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	manifestSchemaLocation   = "https://raw.githubusercontent.com/shopware/shopware/trunk/src/Core/Framework/App/Manifest/Schema/manifest-2.0.xsd"
+	flowActionSchemaLocation = "https://raw.githubusercontent.com/shopware/shopware/trunk/src/Core/Framework/App/FlowAction/Schema/flow-action-1.0.xsd"
+	xmlSchemaInstance        = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+type ManifestFieldMissingError struct {
+	field string
+}
+
+func (e ManifestFieldMissingError) Error() string {
+	return fmt.Sprintf("missing manifest field: %s", e.field)
+}
+
+// Manifest is the manifest.xml of an app. The order of the fields follows the order of the elements in the manifest
+// schema of Shopware.
+type Manifest struct {
+	XMLName        xml.Name `xml:"manifest"`
+	XMLNSXSI       string   `xml:"xmlns:xsi,attr,omitempty"`
+	SchemaLocation string   `xml:"xsi:noNamespaceSchemaLocation,attr,omitempty"`
+
+	Meta        ManifestMeta         `xml:"meta"`
+	Setup       *ManifestSetup       `xml:"setup,omitempty"`
+	Admin       *ManifestAdmin       `xml:"admin,omitempty"`
+	Permissions *ManifestPermissions `xml:"permissions,omitempty"`
+	Webhooks    *ManifestWebhooks    `xml:"webhooks,omitempty"`
+	Payments    *ManifestPayments    `xml:"payments,omitempty"`
+	Tax         *ManifestTax         `xml:"tax,omitempty"`
+	Gateways    *ManifestGateways    `xml:"gateways,omitempty"`
+}
+
+// ManifestTranslation is a translatable text. The text without language is the default.
+type ManifestTranslation struct {
+	Lang  string `xml:"lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type ManifestMeta struct {
+	Name          string                `xml:"name"`
+	Label         []ManifestTranslation `xml:"label"`
+	Description   []ManifestTranslation `xml:"description,omitempty"`
+	Author        string                `xml:"author"`
+	Copyright     string                `xml:"copyright"`
+	Version       string                `xml:"version"`
+	Icon          string                `xml:"icon,omitempty"`
+	License       string                `xml:"license"`
+	Compatibility string                `xml:"compatibility,omitempty"`
+	Privacy       string                `xml:"privacy,omitempty"`
+}
+
+type ManifestSetup struct {
+	RegistrationURL string `xml:"registrationUrl"`
+	Secret          string `xml:"secret,omitempty"`
+}
+
+type ManifestAdmin struct {
+	ActionButtons []ManifestActionButton `xml:"action-button"`
+	Modules       []ManifestModule       `xml:"module"`
+	BaseAppURL    string                 `xml:"base-app-url,omitempty"`
+}
+
+type ManifestActionButton struct {
+	Action string                `xml:"action,attr"`
+	Entity string                `xml:"entity,attr"`
+	View   string                `xml:"view,attr"`
+	URL    string                `xml:"url,attr"`
+	Label  []ManifestTranslation `xml:"label"`
+}
+
+type ManifestModule struct {
+	Name string `xml:"name,attr"`
+	// Source is the URL of the module page. In ManifestConfig, a path is prefixed with the base URL.
+	Source   string                `xml:"source,attr,omitempty"`
+	Parent   string                `xml:"parent,attr"`
+	Position int                   `xml:"position,attr,omitempty"`
+	Label    []ManifestTranslation `xml:"label"`
+}
+
+type ManifestPermissions struct {
+	Read   []string `xml:"read"`
+	Create []string `xml:"create"`
+	Update []string `xml:"update"`
+	Delete []string `xml:"delete"`
+	// Permissions are additional privileges, e.g. "system:cache:info".
+	Permissions []string `xml:"permission"`
+}
+
+func (p ManifestPermissions) empty() bool {
+	return len(p.Read)+len(p.Create)+len(p.Update)+len(p.Delete)+len(p.Permissions) == 0
+}
+
+type ManifestWebhooks struct {
+	Webhooks []ManifestWebhook `xml:"webhook"`
+}
+
+type ManifestWebhook struct {
+	Name            string `xml:"name,attr"`
+	URL             string `xml:"url,attr"`
+	Event           string `xml:"event,attr"`
+	OnlyLiveVersion bool   `xml:"onlyLiveVersion,attr,omitempty"`
+}
+
+type ManifestPayments struct {
+	PaymentMethods []ManifestPaymentMethod `xml:"payment-method"`
+}
+
+type ManifestPaymentMethod struct {
+	Identifier   string                `xml:"identifier"`
+	Name         []ManifestTranslation `xml:"name"`
+	Description  []ManifestTranslation `xml:"description,omitempty"`
+	PayURL       string                `xml:"pay-url,omitempty"`
+	FinalizeURL  string                `xml:"finalize-url,omitempty"`
+	ValidateURL  string                `xml:"validate-url,omitempty"`
+	CaptureURL   string                `xml:"capture-url,omitempty"`
+	RefundURL    string                `xml:"refund-url,omitempty"`
+	RecurringURL string                `xml:"recurring-url,omitempty"`
+	Icon         string                `xml:"icon,omitempty"`
+}
+
+type ManifestTax struct {
+	TaxProviders []ManifestTaxProvider `xml:"tax-provider"`
+}
+
+type ManifestTaxProvider struct {
+	Identifier string `xml:"identifier"`
+	Name       string `xml:"name"`
+	Priority   int    `xml:"priority"`
+	ProcessURL string `xml:"process-url"`
+}
+
+type ManifestGateways struct {
+	Checkout string `xml:"checkout,omitempty"`
+}
+
+// FlowActions is the flow-action.xml of an app.
+type FlowActions struct {
+	XMLName        xml.Name `xml:"flow-actions"`
+	XMLNSXSI       string   `xml:"xmlns:xsi,attr,omitempty"`
+	SchemaLocation string   `xml:"xsi:noNamespaceSchemaLocation,attr,omitempty"`
+
+	FlowActions []ManifestFlowAction `xml:"flow-action"`
+}
+
+type ManifestFlowAction struct {
+	Meta       ManifestFlowActionMeta        `xml:"meta"`
+	Headers    *ManifestFlowActionParameters `xml:"headers,omitempty"`
+	Parameters *ManifestFlowActionParameters `xml:"parameters,omitempty"`
+	Config     *ManifestFlowActionConfig     `xml:"config,omitempty"`
+}
+
+type ManifestFlowActionMeta struct {
+	Name         string                `xml:"name"`
+	Label        []ManifestTranslation `xml:"label"`
+	Description  []ManifestTranslation `xml:"description,omitempty"`
+	URL          string                `xml:"url"`
+	Requirements []string              `xml:"requirements"`
+}
+
+type ManifestFlowActionParameters struct {
+	Parameters []ManifestFlowActionParameter `xml:"parameter"`
+}
+
+type ManifestFlowActionParameter struct {
+	Type  string `xml:"type,attr"`
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type ManifestFlowActionConfig struct {
+	InputFields []ManifestInputField `xml:"input-field"`
+}
+
+type ManifestInputField struct {
+	Type         string                     `xml:"type,attr"`
+	Name         string                     `xml:"name"`
+	Label        []ManifestTranslation      `xml:"label,omitempty"`
+	HelpText     []ManifestTranslation      `xml:"helpText,omitempty"`
+	Required     bool                       `xml:"required,omitempty"`
+	DefaultValue string                     `xml:"defaultValue,omitempty"`
+	Options      *ManifestInputFieldOptions `xml:"options,omitempty"`
+}
+
+type ManifestInputFieldOptions struct {
+	Options []ManifestInputFieldOption `xml:"option"`
+}
+
+type ManifestInputFieldOption struct {
+	Value string                `xml:"value,attr"`
+	Name  []ManifestTranslation `xml:"name"`
+}
+
+// ManifestConfig contains everything needed to generate the manifest.xml, that can't be derived from the registered
+// handlers. Paths are relative to BaseURL and default to the routes used in the README.
+type ManifestConfig struct {
+	// BaseURL is the public URL of the app server, e.g. "https://app.example.com".
+	BaseURL string
+	// Meta describes the app. The name defaults to the name of the server.
+	Meta ManifestMeta
+	// IncludeSecret adds the app secret to the setup. Only do this for apps, which aren't distributed via the
+	// Shopware store.
+	IncludeSecret bool
+	Permissions   ManifestPermissions
+	Modules       []ManifestModule
+	// TaxProviders contain the name and priority of the registered tax providers by their identifier. The name
+	// defaults to the identifier.
+	TaxProviders map[string]ManifestTaxProvider
+
+	RegistrationPath string
+	WebhookPath      string
+	ActionPath       string
+	FlowActionPath   string
+	// PaymentPath may contain the placeholder {operation}, e.g. "/payment/{operation}".
+	PaymentPath string
+	// TaxProviderPath may contain the placeholder {identifier}, e.g. "/tax/{identifier}".
+	TaxProviderPath     string
+	CheckoutGatewayPath string
+}
+
+func (c ManifestConfig) withDefaults() ManifestConfig {
+	defaults := []struct {
+		path  *string
+		value string
+	}{
+		{&c.RegistrationPath, "/setup/register"},
+		{&c.WebhookPath, "/webhooks"},
+		{&c.ActionPath, "/actions"},
+		{&c.FlowActionPath, "/flow-action"},
+		{&c.PaymentPath, "/payment/{operation}"},
+		{&c.TaxProviderPath, "/tax/{identifier}"},
+		{&c.CheckoutGatewayPath, "/checkout/gateway"},
+	}
+
+	for _, d := range defaults {
+		if *d.path == "" {
+			*d.path = d.value
+		}
+	}
+
+	c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+
+	return c
+}
+
+func (c ManifestConfig) url(path string) string {
+	return c.BaseURL + path
+}
+
+// Manifest builds the manifest of the app from the registered handlers. Webhooks registered with a pattern, e.g.
+// "product.*", can't be subscribed in the manifest and are skipped.
+func (srv *Server) Manifest(cfg ManifestConfig) (Manifest, error) {
+	cfg = cfg.withDefaults()
+
+	if cfg.Meta.Name == "" {
+		cfg.Meta.Name = srv.appName
+	}
+
+	required := []struct {
+		field string
+		empty bool
+	}{
+		{"base url", cfg.BaseURL == ""},
+		{"meta.name", cfg.Meta.Name == ""},
+		{"meta.label", len(cfg.Meta.Label) == 0},
+		{"meta.author", cfg.Meta.Author == ""},
+		{"meta.copyright", cfg.Meta.Copyright == ""},
+		{"meta.version", cfg.Meta.Version == ""},
+		{"meta.license", cfg.Meta.License == ""},
+	}
+
+	for _, r := range required {
+		if r.empty {
+			return Manifest{}, ManifestFieldMissingError{field: r.field}
+		}
+	}
+
+	manifest := Manifest{
+		XMLNSXSI:       xmlSchemaInstance,
+		SchemaLocation: manifestSchemaLocation,
+		Meta:           cfg.Meta,
+		Setup:          &ManifestSetup{RegistrationURL: cfg.url(cfg.RegistrationPath)},
+	}
+
+	if cfg.IncludeSecret {
+		manifest.Setup.Secret = srv.appSecret
+	}
+
+	if admin := srv.manifestAdmin(cfg); len(admin.ActionButtons)+len(admin.Modules) > 0 {
+		manifest.Admin = &admin
+	}
+
+	if !cfg.Permissions.empty() {
+		permissions := cfg.Permissions
+		manifest.Permissions = &permissions
+	}
+
+	if webhooks := srv.manifestWebhooks(cfg); len(webhooks) > 0 {
+		manifest.Webhooks = &ManifestWebhooks{Webhooks: webhooks}
+	}
+
+	if paymentMethods := srv.manifestPaymentMethods(cfg); len(paymentMethods) > 0 {
+		manifest.Payments = &ManifestPayments{PaymentMethods: paymentMethods}
+	}
+
+	if taxProviders := srv.manifestTaxProviders(cfg); len(taxProviders) > 0 {
+		manifest.Tax = &ManifestTax{TaxProviders: taxProviders}
+	}
+
+	if srv.checkoutGateway != nil {
+		manifest.Gateways = &ManifestGateways{Checkout: cfg.url(cfg.CheckoutGatewayPath)}
+	}
+
+	return manifest, nil
+}
+
+// GenerateManifest generates the manifest.xml of the app from the registered handlers, see Server.Manifest.
+func (srv *Server) GenerateManifest(cfg ManifestConfig) ([]byte, error) {
+	manifest, err := srv.Manifest(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalManifestXML(manifest)
+}
+
+// GenerateFlowActions generates the flow-action.xml of the app from the registered flow actions. It returns nil, if
+// no flow actions are registered, and a ManifestFieldMissingError, if a flow action has no requirements.
+func (srv *Server) GenerateFlowActions(cfg ManifestConfig) ([]byte, error) {
+	if len(srv.flowActions) == 0 {
+		return nil, nil
+	}
+
+	cfg = cfg.withDefaults()
+	if cfg.BaseURL == "" {
+		return nil, ManifestFieldMissingError{field: "base url"}
+	}
+
+	flowActions := FlowActions{
+		XMLNSXSI:       xmlSchemaInstance,
+		SchemaLocation: flowActionSchemaLocation,
+	}
+
+	for _, name := range sortedKeys(srv.flowActions) {
+		if len(srv.flowActions[name].requirements) == 0 {
+			return nil, ManifestFieldMissingError{field: "flow-action " + name + " requirements"}
+		}
+
+		flowActions.FlowActions = append(flowActions.FlowActions, srv.flowActions[name].manifest(name, cfg.url(cfg.FlowActionPath)))
+	}
+
+	return marshalManifestXML(flowActions)
+}
+
+func (a *flowAction) manifest(name string, url string) ManifestFlowAction {
+	label := a.label
+	if label == "" {
+		label = name
+	}
+
+	flowAction := ManifestFlowAction{
+		Meta: ManifestFlowActionMeta{
+			Name:         name,
+			Label:        manifestText(label),
+			URL:          url,
+			Requirements: a.requirements,
+		},
+		Headers: &ManifestFlowActionParameters{Parameters: []ManifestFlowActionParameter{
+			{Type: "string", Name: "content-type", Value: "application/json"},
+		}},
+	}
+
+	parameters := make(map[string]string, len(a.parameters)+len(a.config))
+	for k, v := range a.parameters {
+		parameters[k] = v
+	}
+
+	var fields []ManifestInputField
+	for _, field := range a.config {
+		parameters[field.Name] = "{{ " + field.Name + " }}"
+
+		inputField := ManifestInputField{
+			Type:         field.Type,
+			Name:         field.Name,
+			Label:        manifestText(field.Label),
+			HelpText:     manifestText(field.HelpText),
+			Required:     field.Required,
+			DefaultValue: field.DefaultValue,
+		}
+
+		if len(field.Options) > 0 {
+			inputField.Options = &ManifestInputFieldOptions{}
+			for _, option := range field.Options {
+				inputField.Options.Options = append(inputField.Options.Options, ManifestInputFieldOption{
+					Value: option,
+					Name:  manifestText(option),
+				})
+			}
+		}
+
+		fields = append(fields, inputField)
+	}
+
+	if len(parameters) > 0 {
+		flowAction.Parameters = &ManifestFlowActionParameters{}
+		for _, name := range sortedKeys(parameters) {
+			flowAction.Parameters.Parameters = append(flowAction.Parameters.Parameters, ManifestFlowActionParameter{
+				Type:  "string",
+				Name:  name,
+				Value: parameters[name],
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		flowAction.Config = &ManifestFlowActionConfig{InputFields: fields}
+	}
+
+	return flowAction
+}
+
+func (srv *Server) manifestAdmin(cfg ManifestConfig) ManifestAdmin {
+	admin := ManifestAdmin{}
+
+	keys := make([]actionKey, 0, len(srv.actions))
+	for key := range srv.actions {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].entity != keys[j].entity {
+			return keys[i].entity < keys[j].entity
+		}
+
+		return keys[i].action < keys[j].action
+	})
+
+	for _, key := range keys {
+		b := srv.actions[key]
+
+		label := b.label
+		if label == "" {
+			label = key.action
+		}
+
+		admin.ActionButtons = append(admin.ActionButtons, ManifestActionButton{
+			Action: key.action,
+			Entity: key.entity,
+			View:   b.view,
+			URL:    cfg.url(cfg.ActionPath),
+			Label:  manifestText(label),
+		})
+	}
+
+	for _, module := range cfg.Modules {
+		if strings.HasPrefix(module.Source, "/") {
+			module.Source = cfg.url(module.Source)
+		}
+
+		admin.Modules = append(admin.Modules, module)
+	}
+
+	return admin
+}
+
+func (srv *Server) manifestWebhooks(cfg ManifestConfig) []ManifestWebhook {
+	events := make([]string, 0, len(srv.webhooks)+1)
+	for event := range srv.webhooks {
+		if !strings.Contains(event, "*") {
+			events = append(events, event)
+		}
+	}
+
+	if _, ok := srv.webhooks[EventSystemConfigWritten]; !ok && srv.configCache != nil {
+		// required to invalidate the app config cache
+		events = append(events, EventSystemConfigWritten)
+	}
+
+	sort.Strings(events)
+
+	webhooks := make([]ManifestWebhook, 0, len(events))
+	names := make(map[string]int)
+	for _, event := range events {
+		name := webhookName(event)
+
+		// names must be unique, but different events might result in the same name, e.g. "a.b" and "a_b"
+		names[name]++
+		if names[name] > 1 {
+			name += strconv.Itoa(names[name])
+		}
+
+		webhooks = append(webhooks, ManifestWebhook{
+			Name:  name,
+			URL:   cfg.url(cfg.WebhookPath),
+			Event: event,
+		})
+	}
+
+	return webhooks
+}
+
+func (srv *Server) manifestPaymentMethods(cfg ManifestConfig) []ManifestPaymentMethod {
+	paymentURL := func(operation PaymentOperation, handler bool) string {
+		if !handler {
+			return ""
+		}
+
+		return cfg.url(strings.ReplaceAll(cfg.PaymentPath, "{operation}", string(operation)))
+	}
+
+	paymentMethods := make([]ManifestPaymentMethod, 0, len(srv.paymentMethods))
+	for _, identifier := range sortedKeys(srv.paymentMethods) {
+		m := srv.paymentMethods[identifier]
+
+		name := m.Name
+		if name == "" {
+			name = identifier
+		}
+
+		paymentMethods = append(paymentMethods, ManifestPaymentMethod{
+			Identifier:   identifier,
+			Name:         manifestText(name),
+			Description:  manifestText(m.Description),
+			PayURL:       paymentURL(PaymentOperationPay, m.Pay != nil),
+			FinalizeURL:  paymentURL(PaymentOperationFinalize, m.Finalize != nil),
+			ValidateURL:  paymentURL(PaymentOperationValidate, m.Validate != nil),
+			CaptureURL:   paymentURL(PaymentOperationCapture, m.Capture != nil),
+			RefundURL:    paymentURL(PaymentOperationRefund, m.Refund != nil),
+			RecurringURL: paymentURL(PaymentOperationRecurring, m.Recurring != nil),
+		})
+	}
+
+	return paymentMethods
+}
+
+func (srv *Server) manifestTaxProviders(cfg ManifestConfig) []ManifestTaxProvider {
+	taxProviders := make([]ManifestTaxProvider, 0, len(srv.taxProviders))
+	for _, identifier := range sortedKeys(srv.taxProviders) {
+		taxProvider := cfg.TaxProviders[identifier]
+		taxProvider.Identifier = identifier
+		taxProvider.ProcessURL = cfg.url(strings.ReplaceAll(cfg.TaxProviderPath, "{identifier}", identifier))
+
+		if taxProvider.Name == "" {
+			taxProvider.Name = identifier
+		}
+
+		taxProviders = append(taxProviders, taxProvider)
+	}
+
+	return taxProviders
+}
+
+func marshalManifestXML(v interface{}) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("encode xml: %w", err)
+	}
+
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// manifestText returns the default translation of a text, or nil for an empty text.
+func manifestText(value string) []ManifestTranslation {
+	if value == "" {
+		return nil
+	}
+
+	return []ManifestTranslation{{Value: value}}
+}
+
+// webhookName converts an event to a webhook name, e.g. "checkout.order.placed" to "checkoutOrderPlaced".
+func webhookName(event string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range event {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = b.Len() > 0
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// ParseManifest parses a manifest.xml, e.g. to check it against the registered handlers with Server.CheckManifest.
+func ParseManifest(data []byte) (Manifest, error) {
+	manifest := Manifest{}
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("parse manifest: %w", err)
+	}
+
+	return manifest, nil
+}
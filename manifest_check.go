@@ -0,0 +1,282 @@
+package appserver
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type ManifestIssueKind string
+
+const (
+	// ManifestMissingHandler is reported for entries of the manifest without a registered handler.
+	ManifestMissingHandler ManifestIssueKind = "missing handler"
+	// ManifestUnusedHandler is reported for registered handlers, which aren't referenced by the manifest.
+	ManifestUnusedHandler ManifestIssueKind = "unused handler"
+	// ManifestURLMismatch is reported for URLs of the manifest, which don't point to the configured path.
+	ManifestURLMismatch ManifestIssueKind = "url mismatch"
+	// ManifestMissingSubscription is reported for webhooks the server needs itself, which the manifest doesn't
+	// subscribe, e.g. system-config.written for WithAppConfigCache.
+	ManifestMissingSubscription ManifestIssueKind = "missing subscription"
+)
+
+// ManifestIssue is an inconsistency between the manifest and the registered handlers.
+type ManifestIssue struct {
+	Kind ManifestIssueKind
+	// Type is the type of the handler, e.g. InvocationTypeWebhook.
+	Type string
+	// Name identifies the handler, e.g. the event of a webhook or "entity/action" for actions.
+	Name string
+	// ExpectedPath and ActualPath are set for URL mismatches.
+	ExpectedPath string
+	ActualPath   string
+}
+
+func (i ManifestIssue) String() string {
+	if i.Kind == ManifestURLMismatch {
+		return fmt.Sprintf("%s %s: %s (expected %s, got %s)", i.Type, i.Name, i.Kind, i.ExpectedPath, i.ActualPath)
+	}
+
+	return fmt.Sprintf("%s %s: %s", i.Type, i.Name, i.Kind)
+}
+
+// ManifestMismatchError is returned by Server.CheckManifest, if the manifest doesn't match the registered handlers.
+type ManifestMismatchError struct {
+	Issues []ManifestIssue
+}
+
+func (e ManifestMismatchError) Error() string {
+	msgs := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		msgs = append(msgs, issue.String())
+	}
+
+	return "manifest doesn't match handlers: " + strings.Join(msgs, "; ")
+}
+
+// CheckManifest compares a hand-written manifest with the registered handlers, see Server.DiffManifest. Call it at
+// startup to fail early.
+func (srv *Server) CheckManifest(manifest Manifest, cfg ManifestConfig) error {
+	if issues := srv.DiffManifest(manifest, cfg); len(issues) > 0 {
+		return ManifestMismatchError{Issues: issues}
+	}
+
+	return nil
+}
+
+// DiffManifest reports entries of the manifest without a registered handler, registered handlers not referenced by
+// the manifest, webhooks the server needs itself, but the manifest doesn't subscribe, and URLs not pointing to the
+// paths of cfg. Only the paths of URLs are compared, prefixed with the path of the base URL, so the manifest may use a
+// different host, e.g. in development. The metadata of cfg is ignored. The fallback handler doesn't count as handler
+// of a webhook, see Server.EventFallback. Flow actions are declared in the flow-action.xml and aren't checked.
+func (srv *Server) DiffManifest(manifest Manifest, cfg ManifestConfig) []ManifestIssue {
+	cfg = cfg.withDefaults()
+
+	d := manifestDiff{}
+	if u, err := url.Parse(cfg.BaseURL); err == nil {
+		d.basePath = u.Path
+	}
+
+	srv.diffManifestWebhooks(&d, manifest, cfg)
+	srv.diffManifestActions(&d, manifest, cfg)
+	srv.diffManifestPaymentMethods(&d, manifest, cfg)
+	srv.diffManifestTaxProviders(&d, manifest, cfg)
+	srv.diffManifestCheckoutGateway(&d, manifest, cfg)
+
+	return d.issues
+}
+
+type manifestDiff struct {
+	// basePath is the path of the base URL, which prefixes all expected paths
+	basePath string
+	issues   []ManifestIssue
+}
+
+func (d *manifestDiff) add(kind ManifestIssueKind, typ string, name string) {
+	d.issues = append(d.issues, ManifestIssue{Kind: kind, Type: typ, Name: name})
+}
+
+// checkURL reports a mismatch, if the path of manifestURL isn't the expected one.
+func (d *manifestDiff) checkURL(typ string, name string, manifestURL string, expectedPath string) {
+	expectedPath = d.basePath + expectedPath
+
+	actualPath := manifestURL
+	if u, err := url.Parse(manifestURL); err == nil {
+		actualPath = u.Path
+	}
+
+	if actualPath != expectedPath {
+		d.issues = append(d.issues, ManifestIssue{
+			Kind:         ManifestURLMismatch,
+			Type:         typ,
+			Name:         name,
+			ExpectedPath: expectedPath,
+			ActualPath:   actualPath,
+		})
+	}
+}
+
+func (srv *Server) diffManifestWebhooks(d *manifestDiff, manifest Manifest, cfg ManifestConfig) {
+	subscribed := make(map[string]bool)
+	if manifest.Webhooks != nil {
+		for _, webhook := range manifest.Webhooks.Webhooks {
+			subscribed[webhook.Event] = true
+
+			handled := srv.hasWebhookHandler(webhook.Event) ||
+				(webhook.Event == EventSystemConfigWritten && srv.configCache != nil)
+			if !handled {
+				d.add(ManifestMissingHandler, InvocationTypeWebhook, webhook.Event)
+				continue
+			}
+
+			d.checkURL(InvocationTypeWebhook, webhook.Event, webhook.URL, cfg.WebhookPath)
+		}
+	}
+
+	// without the webhook, the cache isn't invalidated and serves outdated config until it expires
+	if srv.configCache != nil && !subscribed[EventSystemConfigWritten] {
+		d.add(ManifestMissingSubscription, InvocationTypeWebhook, EventSystemConfigWritten)
+	}
+
+	for _, event := range sortedKeys(srv.webhooks) {
+		used := subscribed[event]
+		for subscribedEvent := range subscribed {
+			used = used || matchEventPattern(event, subscribedEvent)
+		}
+
+		if !used {
+			d.add(ManifestUnusedHandler, InvocationTypeWebhook, event)
+		}
+	}
+}
+
+// hasWebhookHandler reports whether a handler is registered for the event or a matching pattern. Unlike
+// webhookHandlers, the fallback handler isn't considered, so subscriptions of unknown events, e.g. typos, are reported.
+func (srv *Server) hasWebhookHandler(event string) bool {
+	for pattern, handlers := range srv.webhooks {
+		if len(handlers) > 0 && (pattern == event || matchEventPattern(pattern, event)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (srv *Server) diffManifestActions(d *manifestDiff, manifest Manifest, cfg ManifestConfig) {
+	buttons := make(map[actionKey]bool)
+	if manifest.Admin != nil {
+		for _, button := range manifest.Admin.ActionButtons {
+			key := actionKey{entity: button.Entity, action: button.Action}
+			buttons[key] = true
+
+			if _, ok := srv.actions[key]; !ok {
+				d.add(ManifestMissingHandler, InvocationTypeAction, key.String())
+				continue
+			}
+
+			d.checkURL(InvocationTypeAction, key.String(), button.URL, cfg.ActionPath)
+		}
+	}
+
+	keys := make([]actionKey, 0, len(srv.actions))
+	for key := range srv.actions {
+		if !buttons[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, key := range keys {
+		d.add(ManifestUnusedHandler, InvocationTypeAction, key.String())
+	}
+}
+
+func (srv *Server) diffManifestPaymentMethods(d *manifestDiff, manifest Manifest, cfg ManifestConfig) {
+	declared := make(map[string]bool)
+	if manifest.Payments != nil {
+		for _, paymentMethod := range manifest.Payments.PaymentMethods {
+			declared[paymentMethod.Identifier] = true
+
+			m, ok := srv.paymentMethods[paymentMethod.Identifier]
+			if !ok {
+				d.add(ManifestMissingHandler, InvocationTypePayment, paymentMethod.Identifier)
+				continue
+			}
+
+			operations := []struct {
+				operation PaymentOperation
+				url       string
+				handler   bool
+			}{
+				{PaymentOperationPay, paymentMethod.PayURL, m.Pay != nil},
+				{PaymentOperationFinalize, paymentMethod.FinalizeURL, m.Finalize != nil},
+				{PaymentOperationValidate, paymentMethod.ValidateURL, m.Validate != nil},
+				{PaymentOperationCapture, paymentMethod.CaptureURL, m.Capture != nil},
+				{PaymentOperationRefund, paymentMethod.RefundURL, m.Refund != nil},
+				{PaymentOperationRecurring, paymentMethod.RecurringURL, m.Recurring != nil},
+			}
+
+			for _, op := range operations {
+				name := paymentMethod.Identifier + "/" + string(op.operation)
+
+				switch {
+				case op.url == "" && op.handler:
+					d.add(ManifestUnusedHandler, InvocationTypePayment, name)
+				case op.url != "" && !op.handler:
+					d.add(ManifestMissingHandler, InvocationTypePayment, name)
+				case op.url != "":
+					d.checkURL(InvocationTypePayment, name, op.url, strings.ReplaceAll(cfg.PaymentPath, "{operation}", string(op.operation)))
+				}
+			}
+		}
+	}
+
+	for _, identifier := range sortedKeys(srv.paymentMethods) {
+		if !declared[identifier] {
+			d.add(ManifestUnusedHandler, InvocationTypePayment, identifier)
+		}
+	}
+}
+
+func (srv *Server) diffManifestTaxProviders(d *manifestDiff, manifest Manifest, cfg ManifestConfig) {
+	declared := make(map[string]bool)
+	if manifest.Tax != nil {
+		for _, taxProvider := range manifest.Tax.TaxProviders {
+			declared[taxProvider.Identifier] = true
+
+			if _, ok := srv.taxProviders[taxProvider.Identifier]; !ok {
+				d.add(ManifestMissingHandler, InvocationTypeTaxProvider, taxProvider.Identifier)
+				continue
+			}
+
+			d.checkURL(InvocationTypeTaxProvider, taxProvider.Identifier, taxProvider.ProcessURL,
+				strings.ReplaceAll(cfg.TaxProviderPath, "{identifier}", taxProvider.Identifier))
+		}
+	}
+
+	for _, identifier := range sortedKeys(srv.taxProviders) {
+		if !declared[identifier] {
+			d.add(ManifestUnusedHandler, InvocationTypeTaxProvider, identifier)
+		}
+	}
+}
+
+func (srv *Server) diffManifestCheckoutGateway(d *manifestDiff, manifest Manifest, cfg ManifestConfig) {
+	gatewayURL := ""
+	if manifest.Gateways != nil {
+		gatewayURL = manifest.Gateways.Checkout
+	}
+
+	switch {
+	case gatewayURL == "" && srv.checkoutGateway != nil:
+		d.add(ManifestUnusedHandler, InvocationTypeCheckoutGateway, "checkout")
+	case gatewayURL != "" && srv.checkoutGateway == nil:
+		d.add(ManifestMissingHandler, InvocationTypeCheckoutGateway, "checkout")
+	case gatewayURL != "":
+		d.checkURL(InvocationTypeCheckoutGateway, "checkout", gatewayURL, cfg.CheckoutGatewayPath)
+	}
+}
@@ -0,0 +1,307 @@
+package appserver_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newManifestTestServer() *appserver.Server {
+	srv := appserver.NewServer("MyApp", "appsecret", "https://app.example.com/setup/register-confirm",
+		appserver.WithAppConfigCache(time.Minute),
+	)
+
+	noopWebhook := func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error { return nil }
+	srv.Event(appserver.EventOrderPlaced, noopWebhook)
+	srv.Event(appserver.EventProductWritten, noopWebhook)
+	srv.Event("product.*", noopWebhook)
+
+	noopAction := func(_ context.Context, _ appserver.ActionRequest, _ *appserver.APIClient) error { return nil }
+	srv.Action("product", "restockProduct", noopAction, appserver.WithActionLabel("Restock"))
+	srv.Action("order", "exportOrders", noopAction, appserver.WithActionView(appserver.ActionViewList))
+
+	srv.PaymentMethod("myPayment", appserver.PaymentMethod{
+		Name: "My payment",
+		Pay: func(_ context.Context, _ appserver.PaymentPayRequest, _ *appserver.APIClient) (appserver.PaymentResponse, error) {
+			return appserver.PaymentResponse{}, nil
+		},
+		Finalize: func(_ context.Context, _ appserver.PaymentFinalizeRequest, _ *appserver.APIClient) (appserver.PaymentResponse, error) {
+			return appserver.PaymentResponse{}, nil
+		},
+	})
+
+	srv.TaxProvider("myTaxProvider", func(_ context.Context, _ appserver.TaxProviderRequest, _ *appserver.APIClient) (appserver.TaxProviderResponse, error) {
+		return appserver.TaxProviderResponse{}, nil
+	})
+
+	srv.CheckoutGateway(func(_ context.Context, _ appserver.CheckoutGatewayRequest, _ *appserver.APIClient) ([]appserver.CheckoutGatewayCommand, error) {
+		return nil, nil
+	})
+
+	srv.FlowAction("tag.order", func(_ context.Context, _ appserver.FlowActionRequest, _ *appserver.APIClient) error {
+		return nil
+	},
+		appserver.WithFlowActionLabel("Tag order"),
+		appserver.WithFlowActionRequirements("orderAware"),
+		appserver.WithFlowActionParameter("orderNumber", "{{ order.orderNumber }}"),
+		appserver.WithFlowActionConfig(
+			appserver.FlowActionConfigField{Name: "tag", Type: appserver.FlowActionFieldText, Label: "Tag", Required: true},
+			appserver.FlowActionConfigField{Name: "color", Type: appserver.FlowActionFieldSingleSelect, Options: []string{"red", "green"}},
+		),
+	)
+
+	return srv
+}
+
+func newManifestTestConfig() appserver.ManifestConfig {
+	return appserver.ManifestConfig{
+		BaseURL: "https://app.example.com/",
+		Meta: appserver.ManifestMeta{
+			Label: []appserver.ManifestTranslation{
+				{Value: "My app"},
+				{Lang: "de-DE", Value: "Meine App"},
+			},
+			Author:    "Example GmbH",
+			Copyright: "(c) Example GmbH",
+			Version:   "1.0.0",
+			License:   "MIT",
+		},
+		IncludeSecret: true,
+		Permissions: appserver.ManifestPermissions{
+			Read:   []string{"product", "order"},
+			Update: []string{"product"},
+		},
+		Modules: []appserver.ManifestModule{{
+			Name:   "orders",
+			Source: "/module/orders",
+			Parent: "sw-order",
+			Label:  []appserver.ManifestTranslation{{Value: "Order insights"}},
+		}},
+		TaxProviders: map[string]appserver.ManifestTaxProvider{
+			"myTaxProvider": {Name: "My tax provider", Priority: 10},
+		},
+		PaymentPath: "/payment/{operation}",
+	}
+}
+
+func TestServer_GenerateManifest(t *testing.T) {
+	srv := newManifestTestServer()
+
+	manifest, err := srv.GenerateManifest(newManifestTestConfig())
+	require.NoError(t, err)
+
+	expected, err := os.ReadFile("testdata/manifest/manifest.xml")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(manifest))
+
+	flowActions, err := srv.GenerateFlowActions(newManifestTestConfig())
+	require.NoError(t, err)
+
+	expected, err = os.ReadFile("testdata/manifest/flow-action.xml")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(flowActions))
+}
+
+// TestServer_GenerateManifestSchema validates the generated files against the schemas of Shopware. Download them with
+// "make schemas" first, the test is skipped otherwise. In CI, the test fails instead.
+func TestServer_GenerateManifestSchema(t *testing.T) {
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		skipOutsideCI(t, "xmllint is not installed")
+	}
+
+	srv := newManifestTestServer()
+
+	tests := []struct {
+		schema   string
+		generate func(cfg appserver.ManifestConfig) ([]byte, error)
+	}{
+		{schema: "manifest-2.0.xsd", generate: srv.GenerateManifest},
+		{schema: "flow-action-1.0.xsd", generate: srv.GenerateFlowActions},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.schema, func(t *testing.T) {
+			schema := filepath.Join("testdata", "schema", tt.schema)
+			if _, err := os.Stat(schema); err != nil {
+				skipOutsideCI(t, "schema %s is not available, run make schemas", tt.schema)
+			}
+
+			data, err := tt.generate(newManifestTestConfig())
+			require.NoError(t, err)
+
+			file := filepath.Join(t.TempDir(), "generated.xml")
+			require.NoError(t, os.WriteFile(file, data, 0o600))
+
+			out, err := exec.Command(xmllint, "--noout", "--schema", schema, file).CombinedOutput()
+			assert.NoError(t, err, string(out))
+		})
+	}
+}
+
+// skipOutsideCI skips the test, if it's run locally. CI has to provide everything the test needs, so it fails there.
+func skipOutsideCI(t *testing.T, format string, args ...interface{}) {
+	t.Helper()
+
+	if os.Getenv("CI") != "" {
+		t.Fatalf(format, args...)
+	}
+
+	t.Skipf(format, args...)
+}
+
+func TestServer_GenerateFlowActionsMissingRequirements(t *testing.T) {
+	srv := appserver.NewServer("MyApp", "appsecret", "")
+	srv.FlowAction("tag.order", func(_ context.Context, _ appserver.FlowActionRequest, _ *appserver.APIClient) error {
+		return nil
+	})
+
+	_, err := srv.GenerateFlowActions(newManifestTestConfig())
+	assert.EqualError(t, err, "missing manifest field: flow-action tag.order requirements")
+}
+
+func TestServer_GenerateManifestMissingFields(t *testing.T) {
+	srv := newManifestTestServer()
+
+	cfg := newManifestTestConfig()
+	cfg.Meta.Author = ""
+
+	_, err := srv.GenerateManifest(cfg)
+	assert.EqualError(t, err, "missing manifest field: meta.author")
+
+	_, err = srv.GenerateManifest(appserver.ManifestConfig{})
+	assert.ErrorAs(t, err, &appserver.ManifestFieldMissingError{})
+}
+
+func TestServer_CheckManifestGenerated(t *testing.T) {
+	srv := newManifestTestServer()
+
+	for _, baseURL := range []string{"https://app.example.com/", "https://example.com/apps/my-app"} {
+		baseURL := baseURL
+		t.Run(baseURL, func(t *testing.T) {
+			cfg := newManifestTestConfig()
+			cfg.BaseURL = baseURL
+
+			data, err := srv.GenerateManifest(cfg)
+			require.NoError(t, err)
+
+			manifest, err := appserver.ParseManifest(data)
+			require.NoError(t, err)
+
+			assert.Equal(t, "MyApp", manifest.Meta.Name)
+			assert.Equal(t, []appserver.ManifestTranslation{{Value: "My app"}, {Lang: "de-DE", Value: "Meine App"}}, manifest.Meta.Label)
+			assert.Equal(t, []string{"product", "order"}, manifest.Permissions.Read)
+			assert.Len(t, manifest.Webhooks.Webhooks, 3)
+
+			assert.NoError(t, srv.CheckManifest(manifest, cfg))
+		})
+	}
+
+	t.Run("other base path", func(t *testing.T) {
+		data, err := srv.GenerateManifest(newManifestTestConfig())
+		require.NoError(t, err)
+
+		manifest, err := appserver.ParseManifest(data)
+		require.NoError(t, err)
+
+		cfg := newManifestTestConfig()
+		cfg.BaseURL = "https://app.example.com/my-app"
+
+		err = srv.CheckManifest(manifest, cfg)
+		assert.ErrorContains(t, err, "action product/restockProduct: url mismatch (expected /my-app/actions, got /actions)")
+	})
+}
+
+func TestServer_DiffManifest(t *testing.T) {
+	srv := newManifestTestServer()
+	// the fallback must not hide subscriptions without a handler
+	srv.EventFallback(func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error { return nil })
+
+	manifest, err := appserver.ParseManifest([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<manifest xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation="https://raw.githubusercontent.com/shopware/shopware/trunk/src/Core/Framework/App/Manifest/Schema/manifest-2.0.xsd">
+    <meta>
+        <name>MyApp</name>
+        <label>My app</label>
+        <author>Example GmbH</author>
+        <copyright>(c) Example GmbH</copyright>
+        <version>1.0.0</version>
+        <license>MIT</license>
+    </meta>
+    <admin>
+        <action-button action="restockProduct" entity="product" view="detail" url="http://localhost:8080/action">
+            <label>Restock</label>
+        </action-button>
+        <action-button action="deleteProduct" entity="product" view="detail" url="http://localhost:8080/actions">
+            <label>Delete</label>
+        </action-button>
+    </admin>
+    <webhooks>
+        <webhook name="checkoutOrderPlaced" url="http://localhost:8080/webhooks" event="checkout.order.placed"/>
+        <webhook name="customerRegister" url="http://localhost:8080/webhooks" event="checkout.customer.register"/>
+        <webhook name="systemConfigWritten" url="http://localhost:8080/webhooks" event="system-config.written"/>
+    </webhooks>
+    <payments>
+        <payment-method>
+            <identifier>myPayment</identifier>
+            <name>My payment</name>
+            <pay-url>http://localhost:8080/payment/pay</pay-url>
+            <capture-url>http://localhost:8080/payment/capture</capture-url>
+        </payment-method>
+    </payments>
+    <gateways>
+        <checkout>http://localhost:8080/checkout/gateway</checkout>
+    </gateways>
+</manifest>`))
+	require.NoError(t, err)
+
+	issues := srv.DiffManifest(manifest, newManifestTestConfig())
+	assert.Equal(t, []appserver.ManifestIssue{
+		{Kind: appserver.ManifestMissingHandler, Type: "webhook", Name: "checkout.customer.register"},
+		{Kind: appserver.ManifestUnusedHandler, Type: "webhook", Name: "product.*"},
+		{Kind: appserver.ManifestUnusedHandler, Type: "webhook", Name: "product.written"},
+		{Kind: appserver.ManifestURLMismatch, Type: "action", Name: "product/restockProduct", ExpectedPath: "/actions", ActualPath: "/action"},
+		{Kind: appserver.ManifestMissingHandler, Type: "action", Name: "product/deleteProduct"},
+		{Kind: appserver.ManifestUnusedHandler, Type: "action", Name: "order/exportOrders"},
+		{Kind: appserver.ManifestUnusedHandler, Type: "payment", Name: "myPayment/finalize"},
+		{Kind: appserver.ManifestMissingHandler, Type: "payment", Name: "myPayment/capture"},
+		{Kind: appserver.ManifestUnusedHandler, Type: "tax", Name: "myTaxProvider"},
+	}, issues)
+
+	err = srv.CheckManifest(manifest, newManifestTestConfig())
+	assert.ErrorAs(t, err, &appserver.ManifestMismatchError{})
+	assert.Contains(t, err.Error(), "action product/restockProduct: url mismatch (expected /actions, got /action)")
+}
+
+func TestServer_DiffManifestConfigCache(t *testing.T) {
+	manifest, err := appserver.ParseManifest([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<manifest>
+    <meta>
+        <name>MyApp</name>
+    </meta>
+    <webhooks>
+        <webhook name="checkoutOrderPlaced" url="http://localhost:8080/webhooks" event="checkout.order.placed"/>
+    </webhooks>
+</manifest>`))
+	require.NoError(t, err)
+
+	noopWebhook := func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error { return nil }
+
+	srv := appserver.NewServer("MyApp", "appsecret", "", appserver.WithAppConfigCache(time.Minute))
+	srv.Event(appserver.EventOrderPlaced, noopWebhook)
+	assert.Equal(t, []appserver.ManifestIssue{
+		{Kind: appserver.ManifestMissingSubscription, Type: "webhook", Name: "system-config.written"},
+	}, srv.DiffManifest(manifest, newManifestTestConfig()))
+
+	// without the cache, the webhook isn't needed
+	srv = appserver.NewServer("MyApp", "appsecret", "")
+	srv.Event(appserver.EventOrderPlaced, noopWebhook)
+	assert.Empty(t, srv.DiffManifest(manifest, newManifestTestConfig()))
+}
@@ -0,0 +1,128 @@
+package appserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var ErrMediaMissingExtension = errors.New("missing file extension")
+
+// CreateMedia creates an empty media entity, optionally inside the given media folder, and returns its ID.
+// Use UploadMedia or UploadMediaFromURL afterwards to attach the actual file.
+func (c *APIClient) CreateMedia(ctx context.Context, mediaFolderID string) (string, error) {
+	mediaID, err := newID()
+	if err != nil {
+		return "", fmt.Errorf("generate media id: %w", err)
+	}
+
+	payload := map[string]interface{}{"id": mediaID}
+	if mediaFolderID != "" {
+		payload["mediaFolderId"] = mediaFolderID
+	}
+
+	resp, err := c.Request(ctx, http.MethodPost, "/api/media", payload)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return mediaID, nil
+}
+
+// UploadMedia uploads the content of r to an existing media entity. The file name must contain the extension,
+// e.g. "product.jpg". If contentType is empty, it is guessed from the extension.
+func (c *APIClient) UploadMedia(ctx context.Context, mediaID string, fileName string, contentType string, r io.Reader) error {
+	uploadPath, extension, err := mediaUploadPath(mediaID, fileName)
+	if err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		contentType = mime.TypeByExtension("." + extension)
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	resp, err := c.RequestRaw(ctx, http.MethodPost, uploadPath, contentType, r)
+	if err != nil {
+		return err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// UploadMediaFromURL lets the shop download the file from fileURL into an existing media entity. If fileName is empty,
+// the last path segment of fileURL is used.
+func (c *APIClient) UploadMediaFromURL(ctx context.Context, mediaID string, fileName string, fileURL string) error {
+	if fileName == "" {
+		u, err := url.Parse(fileURL)
+		if err != nil {
+			return fmt.Errorf("parse url: %w", err)
+		}
+
+		fileName = path.Base(u.Path)
+	}
+
+	uploadPath, _, err := mediaUploadPath(mediaID, fileName)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Request(ctx, http.MethodPost, uploadPath, map[string]string{"url": fileURL})
+	if err != nil {
+		return err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// mediaUploadPath builds the upload URL path by splitting the file name into name and extension, as expected by Shopware.
+func mediaUploadPath(mediaID string, fileName string) (string, string, error) {
+	extension := strings.TrimPrefix(path.Ext(fileName), ".")
+	name := strings.TrimSuffix(fileName, path.Ext(fileName))
+
+	if extension == "" || name == "" {
+		return "", "", ErrMediaMissingExtension
+	}
+
+	query := url.Values{}
+	query.Set("extension", strings.ToLower(extension))
+	query.Set("fileName", name)
+
+	return "/api/_action/media/" + url.PathEscape(mediaID) + "/upload?" + query.Encode(), extension, nil
+}
+
+// newID generates a random ID in the hex format Shopware uses for entity IDs.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
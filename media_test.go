@@ -0,0 +1,69 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIClient_UploadMedia(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/_action/media/abc/upload", r.URL.Path)
+		assert.Equal(t, "png", r.URL.Query().Get("extension"))
+		assert.Equal(t, "my product", r.URL.Query().Get("fileName"))
+		assert.Equal(t, "image/png", r.Header.Get("content-type"))
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "binary", string(body))
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.UploadMedia(context.Background(), "abc", "my product.PNG", "", strings.NewReader("binary"))
+	assert.NoError(t, err)
+
+	err = client.UploadMedia(context.Background(), "abc", "no-extension", "", strings.NewReader("binary"))
+	assert.ErrorIs(t, err, ErrMediaMissingExtension)
+}
+
+func TestAPIClient_UploadMediaFromURL(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/_action/media/abc/upload", r.URL.Path)
+		assert.Equal(t, "jpg", r.URL.Query().Get("extension"))
+		assert.Equal(t, "image", r.URL.Query().Get("fileName"))
+		assert.Equal(t, "application/json", r.Header.Get("content-type"))
+
+		payload := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "https://example.com/files/image.jpg", payload["url"])
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.UploadMediaFromURL(context.Background(), "abc", "", "https://example.com/files/image.jpg")
+	assert.NoError(t, err)
+}
+
+func TestAPIClient_CreateMedia(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/media", r.URL.Path)
+
+		payload := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Len(t, payload["id"], 32)
+		assert.Equal(t, "folder", payload["mediaFolderId"])
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mediaID, err := client.CreateMedia(context.Background(), "folder")
+	require.NoError(t, err)
+	assert.Len(t, mediaID, 32)
+}
@@ -0,0 +1,99 @@
+package appserver
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	InvocationTypeWebhook = "webhook"
+	InvocationTypeAction  = "action"
+)
+
+// Invocation describes the call of a webhook or action handler.
+type Invocation struct {
+	// Type is one of the InvocationType constants, e.g. InvocationTypeWebhook.
+	Type string
+	// Name is the event of a webhook, the name of an action or flow action, the payment operation or the tax provider
+	// identifier.
+	Name string
+	// Entity is the entity of an action or the identifier of a payment method. It's empty for webhooks.
+	Entity string
+	Source Source
+	// API is passed to the handler. Middlewares may replace it, e.g. to set additional request options.
+	API *APIClient
+}
+
+// PanicError is returned, if a handler or middleware panics.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+func (e PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// InvocationHandler calls the actual webhook or action handler.
+type InvocationHandler func(ctx context.Context, inv Invocation) error
+
+// Middleware wraps the invocation of handlers, e.g. for logging, metrics or access checks.
+type Middleware func(next InvocationHandler) InvocationHandler
+
+// Use adds middlewares, which are applied to all webhook and action handlers. The first middleware is the outermost
+// one and is called first.
+func (srv *Server) Use(middlewares ...Middleware) {
+	srv.middlewares = append(srv.middlewares, middlewares...)
+}
+
+// invoke calls the handler wrapped in all middlewares. Panics are recovered and returned as PanicError, and the
+// context is canceled after the configured handler timeout.
+func (srv *Server) invoke(ctx context.Context, inv Invocation, h InvocationHandler) error {
+	for i := len(srv.middlewares) - 1; i >= 0; i-- {
+		h = srv.middlewares[i](h)
+	}
+
+	if timeout := srv.handlerTimeout(inv); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return recoverHandler(ctx, inv, h)
+}
+
+// recoverHandler calls h and turns a panic into a PanicError.
+func recoverHandler(ctx context.Context, inv Invocation, h InvocationHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return h(ctx, inv)
+}
+
+func (srv *Server) handlerTimeout(inv Invocation) time.Duration {
+	switch inv.Type {
+	case InvocationTypeWebhook:
+		if timeout, ok := srv.eventTimeouts[inv.Name]; ok {
+			return timeout
+		}
+	case InvocationTypeAction:
+		if timeout, ok := srv.actionTimeouts[actionKey{entity: inv.Entity, action: inv.Name}]; ok {
+			return timeout
+		}
+	}
+
+	return srv.defaultTimeout
+}
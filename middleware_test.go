@@ -0,0 +1,149 @@
+package appserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Use(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	var calls []string
+	var invocations []appserver.Invocation
+	errDenied := errors.New("denied")
+
+	srv.Use(func(next appserver.InvocationHandler) appserver.InvocationHandler {
+		return func(ctx context.Context, inv appserver.Invocation) error {
+			calls = append(calls, "outer before")
+			invocations = append(invocations, inv)
+			err := next(ctx, inv)
+			calls = append(calls, "outer after")
+
+			return err
+		}
+	}, func(next appserver.InvocationHandler) appserver.InvocationHandler {
+		return func(ctx context.Context, inv appserver.Invocation) error {
+			if inv.Entity == "order" {
+				return errDenied
+			}
+
+			calls = append(calls, "inner")
+
+			return next(ctx, inv)
+		}
+	})
+
+	srv.Event("product.written", func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		calls = append(calls, "webhook")
+		return nil
+	})
+	srv.Event("product.*", func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		calls = append(calls, "wildcard webhook")
+		return nil
+	})
+	srv.Action("product", "doSomething", func(_ context.Context, _ appserver.ActionRequest, _ *appserver.APIClient) error {
+		calls = append(calls, "action")
+		return nil
+	})
+	srv.Action("order", "doSomething", func(_ context.Context, _ appserver.ActionRequest, _ *appserver.APIClient) error {
+		calls = append(calls, "order action")
+		return nil
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		calls, invocations = nil, nil
+
+		payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`
+		assert.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+
+		// middlewares wrap the whole dispatch, not each handler
+		assert.Equal(t, []string{"outer before", "inner", "webhook", "wildcard webhook", "outer after"}, calls)
+		if assert.Len(t, invocations, 1) {
+			assert.Equal(t, appserver.InvocationTypeWebhook, invocations[0].Type)
+			assert.Equal(t, "product.written", invocations[0].Name)
+			assert.Equal(t, "123", invocations[0].Source.ShopID)
+			assert.NotNil(t, invocations[0].API)
+		}
+	})
+
+	t.Run("action", func(t *testing.T) {
+		calls, invocations = nil, nil
+
+		payload := `{"data":{"ids":["abc"],"entity":"product","action":"doSomething"},"source":{"shopId":"123"}}`
+		assert.NoError(t, srv.HandleAction(appserver.NewSignedTestRequest("mysecret", payload)))
+
+		assert.Equal(t, []string{"outer before", "inner", "action", "outer after"}, calls)
+		if assert.Len(t, invocations, 1) {
+			assert.Equal(t, appserver.InvocationTypeAction, invocations[0].Type)
+			assert.Equal(t, "doSomething", invocations[0].Name)
+			assert.Equal(t, "product", invocations[0].Entity)
+		}
+	})
+
+	t.Run("middleware stops invocation", func(t *testing.T) {
+		calls, invocations = nil, nil
+
+		payload := `{"data":{"ids":["abc"],"entity":"order","action":"doSomething"},"source":{"shopId":"123"}}`
+		assert.ErrorIs(t, srv.HandleAction(appserver.NewSignedTestRequest("mysecret", payload)), errDenied)
+
+		assert.Equal(t, []string{"outer before", "outer after"}, calls)
+	})
+}
+
+func TestServer_HandlerPanic(t *testing.T) {
+	srv := newWebhookTestServer(t)
+	srv.Event("product.written", func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		panic("boom")
+	})
+
+	payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`
+	err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+
+	var panicErr appserver.PanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, "boom", panicErr.Value)
+		assert.Contains(t, string(panicErr.Stack), "TestServer_HandlerPanic")
+	}
+}
+
+func TestServer_HandlerTimeout(t *testing.T) {
+	srv := newWebhookTestServer(t,
+		appserver.WithHandlerTimeout(time.Hour),
+		appserver.WithEventTimeout("product.written", time.Millisecond),
+	)
+
+	deadlines := map[string]time.Duration{}
+	handler := func(ctx context.Context, webhook appserver.WebhookRequest, _ *appserver.APIClient) error {
+		deadline, ok := ctx.Deadline()
+		if assert.True(t, ok) {
+			deadlines[webhook.Data.Event] = time.Until(deadline)
+		}
+
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+	srv.Event("product.written", handler)
+	srv.Event("product.deleted", func(ctx context.Context, webhook appserver.WebhookRequest, api *appserver.APIClient) error {
+		deadline, ok := ctx.Deadline()
+		if assert.True(t, ok) {
+			deadlines[webhook.Data.Event] = time.Until(deadline)
+		}
+
+		return nil
+	})
+
+	payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`
+	err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.LessOrEqual(t, deadlines["product.written"], time.Millisecond)
+
+	payload = `{"data":{"event":"product.deleted","payload":[]},"source":{"shopId":"123"}}`
+	assert.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+	assert.Greater(t, deadlines["product.deleted"], time.Minute)
+}
@@ -1,7 +1,30 @@
 package appserver
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	QueryShopID          = "shop-id"
+	QueryShopURL         = "shop-url"
+	QueryTimestamp       = "timestamp"
+	QueryShopwareVersion = "sw-version"
+	QueryContextLanguage = "sw-context-language"
+	QueryUserLanguage    = "sw-user-language"
+)
+
+// moduleTimestampWindow is how far the timestamp of a module URL may differ from the current time. Signed URLs are
+// only valid for this window, so leaked URLs can't be replayed later.
+const moduleTimestampWindow = 5 * time.Minute
+
+var (
+	ErrModuleTimestampMissing = errors.New("missing module timestamp")
+	ErrModuleTimestampExpired = errors.New("module timestamp outside of the allowed window")
 )
 
 func (srv *Server) VerifyPageSignature(req *http.Request) error {
@@ -11,3 +34,108 @@ func (srv *Server) VerifyPageSignature(req *http.Request) error {
 
 	return nil
 }
+
+// ModuleHandler renders an admin module page. The request is verified before the handler is called.
+type ModuleHandler func(w http.ResponseWriter, req *http.Request, module ModuleRequest, api *APIClient)
+
+// ModuleRequest contains the shop context, which Shopware passes to admin module pages.
+type ModuleRequest struct {
+	ShopID  string
+	ShopURL string
+	// Timestamp is the unix time the shop signed the URL.
+	Timestamp       int64
+	ShopwareVersion string
+	// ContextLanguage is the ID of the content language selected in the administration.
+	ContextLanguage string
+	// UserLanguage is the locale of the administration user, e.g. "en-GB".
+	UserLanguage string
+	// SessionToken authenticates follow-up requests of the page, e.g. XHR calls, see Server.SessionMiddleware. Pass it
+	// to the page and send it in the Authorization header.
+	SessionToken string
+}
+
+// Time returns the time the shop signed the URL.
+func (r ModuleRequest) Time() time.Time {
+	return time.Unix(r.Timestamp, 0)
+}
+
+// Module returns a http.Handler for an admin module page. It verifies the query signature and the timestamp, responds
+// with 401 if either is invalid, and allows the page to be embedded into the administration of the shop only.
+func (srv *Server) Module(handler ModuleHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		module, credentials, err := srv.readModuleRequest(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if origin := shopOrigin(module.ShopURL); origin != "" {
+			w.Header().Set("Content-Security-Policy", "frame-ancestors "+origin)
+		}
+
+		api := srv.newAPIClient(credentials)
+		if module.ContextLanguage != "" {
+			api = api.With(RequestLanguageID(module.ContextLanguage))
+		}
+
+		handler(w, req, module, api)
+	})
+}
+
+func (srv *Server) readModuleRequest(req *http.Request) (ModuleRequest, Credentials, error) {
+	if err := srv.verifyQuerySignature(req); err != nil {
+		return ModuleRequest{}, Credentials{}, err
+	}
+
+	query := req.URL.Query()
+
+	module := ModuleRequest{
+		ShopID:          query.Get(QueryShopID),
+		ShopURL:         query.Get(QueryShopURL),
+		ShopwareVersion: query.Get(QueryShopwareVersion),
+		ContextLanguage: query.Get(QueryContextLanguage),
+		UserLanguage:    query.Get(QueryUserLanguage),
+	}
+
+	timestamp := query.Get(QueryTimestamp)
+	if timestamp == "" {
+		return ModuleRequest{}, Credentials{}, ErrModuleTimestampMissing
+	}
+
+	var err error
+	module.Timestamp, err = strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ModuleRequest{}, Credentials{}, fmt.Errorf("parse timestamp: %w", err)
+	}
+
+	if age := time.Since(module.Time()); age > moduleTimestampWindow || age < -moduleTimestampWindow {
+		return ModuleRequest{}, Credentials{}, ErrModuleTimestampExpired
+	}
+
+	// fetched after verification, as the shop URL might have been updated in the meantime
+	credentials, err := srv.credentialStore.Get(req.Context(), module.ShopID)
+	if err != nil {
+		return ModuleRequest{}, Credentials{}, fmt.Errorf("get shop credentials: %w", err)
+	}
+
+	if module.ShopURL == "" {
+		module.ShopURL = credentials.ShopURL
+	}
+
+	module.SessionToken, err = srv.newSessionToken(module, credentials)
+	if err != nil {
+		return ModuleRequest{}, Credentials{}, fmt.Errorf("issue session token: %w", err)
+	}
+
+	return module, credentials, nil
+}
+
+// shopOrigin returns the scheme and host of the shop URL, as used in the Content-Security-Policy.
+func shopOrigin(shopURL string) string {
+	u, err := url.Parse(shopURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+
+	return u.Scheme + "://" + u.Host
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	appserver "github.com/janbuecker/shopware-appserver-go"
 	"github.com/stretchr/testify/assert"
@@ -42,3 +45,176 @@ func TestServer_VerifyPageSignature(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestServer_Module(t *testing.T) {
+	store := appserver.NewMemoryCredentialStore()
+	require.NoError(t, store.Store(context.Background(), appserver.Credentials{
+		ShopID:     "123",
+		ShopURL:    "https://shop.example/shopware",
+		ShopSecret: "mysecret",
+	}))
+
+	srv := appserver.NewServer("", "mysecret", "", appserver.WithCredentialStore(store))
+
+	var module appserver.ModuleRequest
+	handler := srv.Module(func(w http.ResponseWriter, _ *http.Request, m appserver.ModuleRequest, api *appserver.APIClient) {
+		assert.NotNil(t, api)
+		module = m
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	now := time.Now().Unix()
+	timestamp := strconv.FormatInt(now, 10)
+
+	t.Run("valid signature", func(t *testing.T) {
+		query := appserver.SignTestQuery("mysecret", "shop-id=123&shop-url=https://shop.example/shopware&timestamp="+timestamp+
+			"&sw-version=6.6.0.0&sw-context-language=2fbb5fe2e29a4d70aa5854ce7ce3e20b&sw-user-language=de-DE")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/module?"+query, nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "frame-ancestors https://shop.example", rec.Header().Get("Content-Security-Policy"))
+		assert.NotEmpty(t, module.SessionToken)
+
+		module.SessionToken = ""
+		assert.Equal(t, appserver.ModuleRequest{
+			ShopID:          "123",
+			ShopURL:         "https://shop.example/shopware",
+			Timestamp:       now,
+			ShopwareVersion: "6.6.0.0",
+			ContextLanguage: "2fbb5fe2e29a4d70aa5854ce7ce3e20b",
+			UserLanguage:    "de-DE",
+		}, module)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		query := appserver.SignTestQuery("wrongsecret", "shop-id=123&shop-url=https://shop.example/shopware&timestamp="+timestamp)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/module?"+query, nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+	})
+
+	timestampTests := []struct {
+		name      string
+		timestamp string
+		expected  string
+	}{
+		{name: "missing timestamp", expected: appserver.ErrModuleTimestampMissing.Error()},
+		{name: "expired timestamp", timestamp: "&timestamp=1700000000", expected: appserver.ErrModuleTimestampExpired.Error()},
+		{
+			name:      "timestamp in the future",
+			timestamp: "&timestamp=" + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+			expected:  appserver.ErrModuleTimestampExpired.Error(),
+		},
+	}
+
+	for _, tt := range timestampTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			query := appserver.SignTestQuery("mysecret", "shop-id=123&shop-url=https://shop.example/shopware"+tt.timestamp)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/module?"+query, nil))
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, tt.expected+"\n", rec.Body.String())
+		})
+	}
+}
+
+func TestServer_VerifyPageSignatureCanonicalization(t *testing.T) {
+	store := appserver.NewMemoryCredentialStore()
+	require.NoError(t, store.Store(context.Background(), appserver.Credentials{
+		ShopID:     "123",
+		ShopURL:    "https://shop.example",
+		ShopSecret: "mysecret",
+	}))
+
+	srv := appserver.NewServer("", "mysecret", "", appserver.WithCredentialStore(store))
+
+	// Shopware signs the query string exactly as it's sent, including its percent-encoding. The signatures are fixed,
+	// so the test doesn't share the signing code with the server. They're computed independently with
+	//   printf '%s' "$query" | openssl dgst -sha256 -hmac mysecret
+	// and not captured from a live shop.
+	const (
+		query                 = "shop-id=123&shop-url=https%3A%2F%2Fshop.example&timestamp=1700000000&sw-version=6.6.0.0&sw-context-language=2fbb5fe2e29a4d70aa5854ce7ce3e20b&sw-user-language=de-DE"
+		signature             = "0f2a498e205e2781324b3335347385e1273bd3332d64747bc172f47e961a98ea"
+		specialCharsQuery     = "shop-id=123&shop-url=https%3A%2F%2Fshop.example&timestamp=1700000000&search=a+b%2Bc%25d"
+		specialCharsSignature = "b3311e2f137abcaecd59e36dc61351dde25ae10bcbad558c3702603b0fd6661f"
+		// signature of "shop-id=123&shop-url=https://shop.example&timestamp=1700000000"
+		unescapedSignature = "c2d8c631e0ad5998d680422602ccf971ef12db3051226c78ef757f0a99bacd64"
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		valid bool
+	}{
+		{
+			name:  "signature last",
+			query: query + "&shopware-shop-signature=" + signature,
+			valid: true,
+		},
+		{
+			name:  "signature first",
+			query: "shopware-shop-signature=" + signature + "&" + query,
+			valid: true,
+		},
+		{
+			name:  "signature in the middle",
+			query: "shop-id=123&shop-url=https%3A%2F%2Fshop.example&timestamp=1700000000&shopware-shop-signature=" + signature + "&sw-version=6.6.0.0&sw-context-language=2fbb5fe2e29a4d70aa5854ce7ce3e20b&sw-user-language=de-DE",
+			valid: true,
+		},
+		{
+			name:  "encoded signature key",
+			query: query + "&shopware%2Dshop%2Dsignature=" + signature,
+			valid: true,
+		},
+		{
+			name:  "plus and percent in values",
+			query: specialCharsQuery + "&shopware-shop-signature=" + specialCharsSignature,
+			valid: true,
+		},
+		{
+			name:  "signed unescaped",
+			query: "shop-id=123&shop-url=https%3A%2F%2Fshop.example&timestamp=1700000000&shopware-shop-signature=" + unescapedSignature,
+		},
+		{
+			name:  "reordered parameters",
+			query: "shop-url=https%3A%2F%2Fshop.example&shop-id=123&timestamp=1700000000&sw-version=6.6.0.0&sw-context-language=2fbb5fe2e29a4d70aa5854ce7ce3e20b&sw-user-language=de-DE&shopware-shop-signature=" + signature,
+		},
+		{
+			name:  "modified value",
+			query: strings.Replace(query, "de-DE", "en-GB", 1) + "&shopware-shop-signature=" + signature,
+		},
+		{
+			name:  "decoded plus",
+			query: strings.Replace(specialCharsQuery, "%2B", "+", 1) + "&shopware-shop-signature=" + specialCharsSignature,
+		},
+		{
+			name:  "duplicate signature",
+			query: query + "&shopware-shop-signature=" + signature + "&shopware-shop-signature=" + signature,
+		},
+		{
+			name:  "missing signature",
+			query: query,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := srv.VerifyPageSignature(httptest.NewRequest(http.MethodGet, "/page/foo?"+tt.query, nil))
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, "invalid signature")
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	InvocationTypePayment = "payment"
+
+	// Payment statuses are the transitions of the order transaction state machine.
+	PaymentStatusPaid      = "paid"
+	PaymentStatusAuthorize = "authorize"
+	PaymentStatusProcess   = "process"
+	PaymentStatusFail      = "fail"
+	PaymentStatusCancel    = "cancel"
+
+	// Refund statuses are the transitions of the refund state machine.
+	RefundStatusComplete = "complete"
+	RefundStatusProcess  = "process"
+	RefundStatusFail     = "fail"
+	RefundStatusCancel   = "cancel"
+)
+
+// PaymentOperation is one of the synchronous calls Shopware sends to payment apps. Each operation has its own URL in
+// the manifest.xml.
+type PaymentOperation string
+
+const (
+	PaymentOperationPay       PaymentOperation = "pay"
+	PaymentOperationFinalize  PaymentOperation = "finalize"
+	PaymentOperationValidate  PaymentOperation = "validate"
+	PaymentOperationCapture   PaymentOperation = "capture"
+	PaymentOperationRefund    PaymentOperation = "refund"
+	PaymentOperationRecurring PaymentOperation = "recurring"
+)
+
+var ErrPaymentMethodMissing = errors.New("missing payment method identifier")
+
+type PaymentMethodNotFoundError struct {
+	identifier string
+}
+
+func (e PaymentMethodNotFoundError) Error() string {
+	return fmt.Sprintf("no payment method found for identifier: %s", e.identifier)
+}
+
+type PaymentOperationNotSupportedError struct {
+	identifier string
+	operation  PaymentOperation
+}
+
+func (e PaymentOperationNotSupportedError) Error() string {
+	return fmt.Sprintf("payment method %s does not support operation %s", e.identifier, e.operation)
+}
+
+// PaymentMethod contains the handlers of a payment method. Only the handlers of the operations configured in the
+// manifest.xml need to be set.
+type PaymentMethod struct {
+	// Name and Description are shown to customers. They're used to generate the manifest.xml.
+	Name        string
+	Description string
+
+	// Pay is called when the order is placed. Return a redirect URL for asynchronous payments.
+	Pay func(ctx context.Context, payment PaymentPayRequest, api *APIClient) (PaymentResponse, error)
+	// Finalize is called when the customer returns from the redirect URL of an asynchronous payment.
+	Finalize func(ctx context.Context, payment PaymentFinalizeRequest, api *APIClient) (PaymentResponse, error)
+	// Validate is called before the order is placed, e.g. to check the request data sent by the storefront.
+	Validate func(ctx context.Context, payment PaymentValidateRequest, api *APIClient) (PaymentValidateResponse, error)
+	// Capture is called after the order is placed for payments, which were validated beforehand.
+	Capture func(ctx context.Context, payment PaymentCaptureRequest, api *APIClient) (PaymentResponse, error)
+	// Refund is called when a refund is started in the administration.
+	Refund func(ctx context.Context, payment PaymentRefundRequest, api *APIClient) (PaymentResponse, error)
+	// Recurring is called for recurring payments, e.g. of subscriptions.
+	Recurring func(ctx context.Context, payment PaymentRecurringRequest, api *APIClient) (PaymentResponse, error)
+}
+
+type PaymentPayRequest struct {
+	*AppRequest
+
+	Order            Order                  `json:"order"`
+	OrderTransaction OrderTransaction       `json:"orderTransaction"`
+	ReturnURL        string                 `json:"returnUrl"`
+	RequestData      map[string]interface{} `json:"requestData"`
+}
+
+type PaymentFinalizeRequest struct {
+	*AppRequest
+
+	OrderTransaction OrderTransaction       `json:"orderTransaction"`
+	QueryParameters  map[string]interface{} `json:"queryParameters"`
+}
+
+type PaymentValidateRequest struct {
+	*AppRequest
+
+	Cart                Cart                   `json:"cart"`
+	RequestData         map[string]interface{} `json:"requestData"`
+	SalesChannelContext SalesChannelContext    `json:"salesChannelContext"`
+}
+
+type PaymentCaptureRequest struct {
+	*AppRequest
+
+	Order            Order                  `json:"order"`
+	OrderTransaction OrderTransaction       `json:"orderTransaction"`
+	PreOrderPayment  map[string]interface{} `json:"preOrderPayment"`
+}
+
+type PaymentRefundRequest struct {
+	*AppRequest
+
+	Order  Order  `json:"order"`
+	Refund Refund `json:"refund"`
+}
+
+type PaymentRecurringRequest struct {
+	*AppRequest
+
+	Order            Order            `json:"order"`
+	OrderTransaction OrderTransaction `json:"orderTransaction"`
+}
+
+// PaymentResponse is the response to all payment operations except validate.
+type PaymentResponse struct {
+	// Status is the transition of the order transaction or refund state machine, e.g. PaymentStatusPaid.
+	Status string `json:"status,omitempty"`
+	// Message is shown to the customer, if the payment failed.
+	Message string `json:"message,omitempty"`
+	// RedirectURL is the URL the customer is sent to by asynchronous payments.
+	RedirectURL string `json:"redirectUrl,omitempty"`
+}
+
+// PaymentValidateResponse is the response to the validate operation.
+type PaymentValidateResponse struct {
+	// PreOrderPayment is stored with the order and sent back to the capture operation.
+	PreOrderPayment interface{} `json:"preOrderPayment,omitempty"`
+	// Message is shown to the customer, if the validation failed.
+	Message string `json:"message,omitempty"`
+}
+
+type OrderTransaction struct {
+	ID                string                 `json:"id"`
+	VersionID         string                 `json:"versionId"`
+	OrderID           string                 `json:"orderId"`
+	PaymentMethodID   string                 `json:"paymentMethodId"`
+	Amount            CalculatedPrice        `json:"amount"`
+	PaymentMethod     *PaymentMethodEntity   `json:"paymentMethod"`
+	StateMachineState *StateMachineState     `json:"stateMachineState"`
+	CustomFields      map[string]interface{} `json:"customFields"`
+}
+
+type PaymentMethodEntity struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	TechnicalName    string            `json:"technicalName"`
+	AppPaymentMethod *AppPaymentMethod `json:"appPaymentMethod"`
+}
+
+type AppPaymentMethod struct {
+	ID         string `json:"id"`
+	Identifier string `json:"identifier"`
+	AppName    string `json:"appName"`
+}
+
+type Refund struct {
+	ID                 string                  `json:"id"`
+	Reason             string                  `json:"reason"`
+	Amount             CalculatedPrice         `json:"amount"`
+	StateMachineState  *StateMachineState      `json:"stateMachineState"`
+	TransactionCapture OrderTransactionCapture `json:"transactionCapture"`
+	CustomFields       map[string]interface{}  `json:"customFields"`
+}
+
+type OrderTransactionCapture struct {
+	ID          string           `json:"id"`
+	ExternalRef string           `json:"externalReference"`
+	Amount      CalculatedPrice  `json:"amount"`
+	Transaction OrderTransaction `json:"transaction"`
+}
+
+// PaymentMethod registers the handlers of a payment method by the identifier used in the manifest.xml.
+func (srv *Server) PaymentMethod(identifier string, method PaymentMethod) {
+	srv.paymentMethods[identifier] = method
+}
+
+// HandlePayment verifies and handles a payment operation. The payment method is determined by the identifier of the
+// app payment method sent in the payload. If it's missing and only one payment method is registered, that one is
+// used. The returned response must be written with SignedResponse.Write.
+func (srv *Server) HandlePayment(req *http.Request, operation PaymentOperation) (SignedResponse, error) {
+	switch operation {
+	case PaymentOperationPay:
+		return handlePaymentOperation(srv, req, operation, func(m PaymentMethod) func(context.Context, PaymentPayRequest, *APIClient) (PaymentResponse, error) {
+			return m.Pay
+		})
+	case PaymentOperationFinalize:
+		return handlePaymentOperation(srv, req, operation, func(m PaymentMethod) func(context.Context, PaymentFinalizeRequest, *APIClient) (PaymentResponse, error) {
+			return m.Finalize
+		})
+	case PaymentOperationValidate:
+		return handlePaymentOperation(srv, req, operation, func(m PaymentMethod) func(context.Context, PaymentValidateRequest, *APIClient) (PaymentValidateResponse, error) {
+			return m.Validate
+		})
+	case PaymentOperationCapture:
+		return handlePaymentOperation(srv, req, operation, func(m PaymentMethod) func(context.Context, PaymentCaptureRequest, *APIClient) (PaymentResponse, error) {
+			return m.Capture
+		})
+	case PaymentOperationRefund:
+		return handlePaymentOperation(srv, req, operation, func(m PaymentMethod) func(context.Context, PaymentRefundRequest, *APIClient) (PaymentResponse, error) {
+			return m.Refund
+		})
+	case PaymentOperationRecurring:
+		return handlePaymentOperation(srv, req, operation, func(m PaymentMethod) func(context.Context, PaymentRecurringRequest, *APIClient) (PaymentResponse, error) {
+			return m.Recurring
+		})
+	default:
+		return SignedResponse{}, fmt.Errorf("unknown payment operation: %s", operation)
+	}
+}
+
+// paymentRequest is implemented by all payment request types.
+type paymentRequest interface {
+	source() Source
+}
+
+func (r PaymentPayRequest) source() Source       { return r.Source }
+func (r PaymentFinalizeRequest) source() Source  { return r.Source }
+func (r PaymentValidateRequest) source() Source  { return r.Source }
+func (r PaymentCaptureRequest) source() Source   { return r.Source }
+func (r PaymentRefundRequest) source() Source    { return r.Source }
+func (r PaymentRecurringRequest) source() Source { return r.Source }
+
+func handlePaymentOperation[Req paymentRequest, Resp any](
+	srv *Server,
+	req *http.Request,
+	operation PaymentOperation,
+	handler func(m PaymentMethod) func(context.Context, Req, *APIClient) (Resp, error),
+) (SignedResponse, error) {
+	body, credentials, secret, err := srv.readSignedRequest(req)
+	if err != nil {
+		return SignedResponse{}, err
+	}
+
+	var paymentReq Req
+	if err := json.Unmarshal(body, &paymentReq); err != nil {
+		return SignedResponse{}, fmt.Errorf("parse body: %w", err)
+	}
+
+	identifier, method, err := srv.resolvePaymentMethod(body, operation)
+	if err != nil {
+		return SignedResponse{}, err
+	}
+
+	h := handler(method)
+	if h == nil {
+		return SignedResponse{}, PaymentOperationNotSupportedError{identifier: identifier, operation: operation}
+	}
+
+	inv := Invocation{
+		Type:   InvocationTypePayment,
+		Name:   string(operation),
+		Entity: identifier,
+		Source: paymentReq.source(),
+		API:    srv.newAPIClient(credentials),
+	}
+
+	return srv.invokeSigned(req.Context(), inv, secret, func(ctx context.Context, api *APIClient) (interface{}, error) {
+		return h(ctx, paymentReq, api)
+	})
+}
+
+// resolvePaymentMethod finds the payment method by the identifier of the app payment method in the verified payload.
+func (srv *Server) resolvePaymentMethod(body []byte, operation PaymentOperation) (string, PaymentMethod, error) {
+	payload := struct {
+		OrderTransaction    OrderTransaction    `json:"orderTransaction"`
+		SalesChannelContext SalesChannelContext `json:"salesChannelContext"`
+		Refund              Refund              `json:"refund"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", PaymentMethod{}, fmt.Errorf("parse body: %w", err)
+	}
+
+	var paymentMethod *PaymentMethodEntity
+	switch operation {
+	case PaymentOperationValidate:
+		paymentMethod = &payload.SalesChannelContext.PaymentMethod
+	case PaymentOperationRefund:
+		paymentMethod = payload.Refund.TransactionCapture.Transaction.PaymentMethod
+	case PaymentOperationPay, PaymentOperationFinalize, PaymentOperationCapture, PaymentOperationRecurring:
+		paymentMethod = payload.OrderTransaction.PaymentMethod
+	}
+
+	if paymentMethod != nil && paymentMethod.AppPaymentMethod != nil && paymentMethod.AppPaymentMethod.Identifier != "" {
+		identifier := paymentMethod.AppPaymentMethod.Identifier
+
+		method, ok := srv.paymentMethods[identifier]
+		if !ok {
+			return "", PaymentMethod{}, PaymentMethodNotFoundError{identifier: identifier}
+		}
+
+		return identifier, method, nil
+	}
+
+	if len(srv.paymentMethods) == 1 {
+		for identifier, method := range srv.paymentMethods {
+			return identifier, method, nil
+		}
+	}
+
+	return "", PaymentMethod{}, ErrPaymentMethodMissing
+}
@@ -0,0 +1,97 @@
+package appserver_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertSignedResponse(t *testing.T, resp appserver.SignedResponse, secret string, expectedBody string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, resp.Write(rec))
+
+	assert.Equal(t, hex.EncodeToString(appserver.SignTestData(secret, rec.Body.String())), rec.Header().Get(appserver.AppSignatureKey))
+	assert.Equal(t, "application/json", rec.Header().Get("content-type"))
+	assert.JSONEq(t, expectedBody, rec.Body.String())
+}
+
+func TestServer_HandlePayment(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	srv.PaymentMethod("asyncPayment", appserver.PaymentMethod{
+		Pay: func(_ context.Context, payment appserver.PaymentPayRequest, api *appserver.APIClient) (appserver.PaymentResponse, error) {
+			assert.NotNil(t, api)
+			assert.Equal(t, "10001", payment.Order.OrderNumber)
+			assert.Equal(t, 19.99, payment.OrderTransaction.Amount.TotalPrice)
+			assert.Equal(t, "tok_123", payment.RequestData["token"])
+
+			return appserver.PaymentResponse{RedirectURL: payment.ReturnURL + "&provider=1"}, nil
+		},
+	})
+	srv.PaymentMethod("syncPayment", appserver.PaymentMethod{
+		Pay: func(_ context.Context, _ appserver.PaymentPayRequest, _ *appserver.APIClient) (appserver.PaymentResponse, error) {
+			return appserver.PaymentResponse{Status: appserver.PaymentStatusPaid}, nil
+		},
+		Validate: func(_ context.Context, payment appserver.PaymentValidateRequest, _ *appserver.APIClient) (appserver.PaymentValidateResponse, error) {
+			assert.Equal(t, 19.99, payment.Cart.Price.TotalPrice)
+
+			return appserver.PaymentValidateResponse{PreOrderPayment: map[string]string{"reference": "abc"}}, nil
+		},
+	})
+
+	payTransaction := func(identifier string) string {
+		return `{"source":{"shopId":"123"},"order":{"orderNumber":"10001"},"returnUrl":"https://shop.example.com/return?token=x",` +
+			`"requestData":{"token":"tok_123"},"orderTransaction":{"amount":{"totalPrice":19.99},` +
+			`"paymentMethod":{"appPaymentMethod":{"identifier":"` + identifier + `"}}}}`
+	}
+
+	t.Run("async pay", func(t *testing.T) {
+		resp, err := srv.HandlePayment(appserver.NewSignedTestRequest("mysecret", payTransaction("asyncPayment")), appserver.PaymentOperationPay)
+		require.NoError(t, err)
+		assertSignedResponse(t, resp, "mysecret", `{"redirectUrl":"https://shop.example.com/return?token=x&provider=1"}`)
+	})
+
+	t.Run("sync pay", func(t *testing.T) {
+		resp, err := srv.HandlePayment(appserver.NewSignedTestRequest("mysecret", payTransaction("syncPayment")), appserver.PaymentOperationPay)
+		require.NoError(t, err)
+		assertSignedResponse(t, resp, "mysecret", `{"status":"paid"}`)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		payload := `{"source":{"shopId":"123"},"cart":{"price":{"totalPrice":19.99}},` +
+			`"salesChannelContext":{"paymentMethod":{"appPaymentMethod":{"identifier":"syncPayment"}}}}`
+
+		resp, err := srv.HandlePayment(appserver.NewSignedTestRequest("mysecret", payload), appserver.PaymentOperationValidate)
+		require.NoError(t, err)
+		assertSignedResponse(t, resp, "mysecret", `{"preOrderPayment":{"reference":"abc"}}`)
+	})
+
+	t.Run("unsupported operation", func(t *testing.T) {
+		_, err := srv.HandlePayment(appserver.NewSignedTestRequest("mysecret", payTransaction("asyncPayment")), appserver.PaymentOperationCapture)
+		assert.EqualError(t, err, "payment method asyncPayment does not support operation capture")
+	})
+
+	t.Run("unknown payment method", func(t *testing.T) {
+		_, err := srv.HandlePayment(appserver.NewSignedTestRequest("mysecret", payTransaction("unknown")), appserver.PaymentOperationPay)
+		assert.ErrorAs(t, err, &appserver.PaymentMethodNotFoundError{})
+	})
+
+	t.Run("missing identifier", func(t *testing.T) {
+		payload := `{"source":{"shopId":"123"},"orderTransaction":{}}`
+
+		_, err := srv.HandlePayment(appserver.NewSignedTestRequest("mysecret", payload), appserver.PaymentOperationPay)
+		assert.ErrorIs(t, err, appserver.ErrPaymentMethodMissing)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		_, err := srv.HandlePayment(appserver.NewSignedTestRequest("othersecret", payTransaction("syncPayment")), appserver.PaymentOperationPay)
+		assert.EqualError(t, err, "invalid signature")
+	})
+}
@@ -9,10 +9,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-
-	"github.com/thanhpk/randstr"
+	"sync"
+	"time"
 )
 
+// pendingRegistrationTTL is the time a registration of a known shop waits for its confirmation.
+const pendingRegistrationTTL = 10 * time.Minute
+
 type RegistrationResponse struct {
 	Proof           string `json:"proof"`
 	Secret          string `json:"secret"`
@@ -43,11 +46,25 @@ func (srv *Server) HandleRegistration(req *http.Request) (RegistrationResponse,
 	h := hmac.New(sha256.New, []byte(srv.appSecret))
 	h.Write([]byte(credentials.ShopID + credentials.ShopURL + srv.appName))
 
-	credentials.ShopSecret = randstr.Base62(16)
-
-	err = srv.credentialStore.Store(req.Context(), credentials)
+	credentials.ShopSecret, err = generateShopSecret()
 	if err != nil {
-		return RegistrationResponse{}, fmt.Errorf("store shop credentials: %w", err)
+		return RegistrationResponse{}, fmt.Errorf("generate shop secret: %w", err)
+	}
+
+	_, err = srv.credentialStore.Get(req.Context(), credentials.ShopID)
+	switch {
+	case err == nil:
+		// Shopware registers the shop again, e.g. after the app was reinstalled or the shop URL changed. The app secret
+		// is shared by all shops, so anyone could send this request: keep the stored credentials until the shop
+		// confirms the registration with the new secret.
+		srv.pendingRegistrations.Store(credentials)
+	case errors.Is(err, ErrCredentialsNotFound):
+		err = srv.credentialStore.Store(req.Context(), credentials)
+		if err != nil {
+			return RegistrationResponse{}, fmt.Errorf("store shop credentials: %w", err)
+		}
+	default:
+		return RegistrationResponse{}, fmt.Errorf("get shop credentials: %w", err)
 	}
 
 	return RegistrationResponse{
@@ -57,6 +74,9 @@ func (srv *Server) HandleRegistration(req *http.Request) (RegistrationResponse,
 	}, nil
 }
 
+// HandleConfirm stores the API keys of a registered shop. The request has to be signed with the secret returned by
+// HandleRegistration. If the shop was registered before, its URL and secret are only updated now, and the previous
+// API keys are dropped, if the URL changed.
 func (srv *Server) HandleConfirm(req *http.Request) error {
 	body, err := extractBody(req)
 	if err != nil {
@@ -73,18 +93,106 @@ func (srv *Server) HandleConfirm(req *http.Request) error {
 		return fmt.Errorf("parse body: %w", err)
 	}
 
+	signature, err := hex.DecodeString(req.Header.Get(ShopSignatureKey))
+	if err != nil {
+		return SignatureVerificationError{err: fmt.Errorf("decode signature: %w", err)}
+	}
+
 	credentials, err := srv.credentialStore.Get(req.Context(), confirmReq.ShopID)
 	if err != nil {
 		return fmt.Errorf("get shop credentials: %w", err)
 	}
 
-	credentials.APIKey = confirmReq.APIKey
-	credentials.SecretKey = confirmReq.SecretKey
+	pending, ok := srv.pendingRegistrations.Get(confirmReq.ShopID)
+	if !ok {
+		// first registration, the credentials were stored with the new secret
+		pending = credentials
+	}
+
+	// only the old secret is valid, if the shop doesn't know the new one
+	if err := verifySignature(body, signature, pending.ShopSecret); err != nil {
+		return SignatureVerificationError{err: err}
+	}
+
+	if ok {
+		if normalizeShopURL(pending.ShopURL) != normalizeShopURL(credentials.ShopURL) {
+			// the API keys belong to the old URL
+			credentials.APIKey = ""
+			credentials.SecretKey = ""
+		}
+
+		credentials.ShopURL = pending.ShopURL
+		credentials.Timestamp = pending.Timestamp
+		srv.setShopSecret(&credentials, pending.ShopSecret)
+	}
+
+	if confirmReq.APIKey != "" {
+		credentials.APIKey = confirmReq.APIKey
+		credentials.SecretKey = confirmReq.SecretKey
+	}
 
 	err = srv.credentialStore.Store(req.Context(), credentials)
 	if err != nil {
 		return fmt.Errorf("store shop credentials: %w", err)
 	}
 
+	srv.pendingRegistrations.Delete(credentials.ShopID)
+
+	// cached tokens might belong to the previous API keys
+	srv.tokenStore.Delete(credentials.ShopID)
+
 	return nil
 }
+
+// pendingRegistrations holds registrations of known shops until they're confirmed. They're kept in memory, so the
+// confirmation has to reach the same process as the registration.
+type pendingRegistrations struct {
+	registrations   map[string]pendingRegistration
+	registrationsMu sync.Mutex
+}
+
+type pendingRegistration struct {
+	credentials Credentials
+	expiresAt   time.Time
+}
+
+func newPendingRegistrations() *pendingRegistrations {
+	return &pendingRegistrations{
+		registrations: make(map[string]pendingRegistration),
+	}
+}
+
+// Store replaces the pending registration of the shop.
+func (p *pendingRegistrations) Store(credentials Credentials) {
+	p.registrationsMu.Lock()
+	defer p.registrationsMu.Unlock()
+
+	p.registrations[credentials.ShopID] = pendingRegistration{
+		credentials: credentials,
+		expiresAt:   time.Now().Add(pendingRegistrationTTL),
+	}
+}
+
+func (p *pendingRegistrations) Get(shopID string) (Credentials, bool) {
+	p.registrationsMu.Lock()
+	defer p.registrationsMu.Unlock()
+
+	registration, ok := p.registrations[shopID]
+	if !ok {
+		return Credentials{}, false
+	}
+
+	if time.Now().After(registration.expiresAt) {
+		delete(p.registrations, shopID)
+		return Credentials{}, false
+	}
+
+	return registration.credentials, true
+}
+
+func (p *pendingRegistrations) Delete(shopID string) {
+	p.registrationsMu.Lock()
+	defer p.registrationsMu.Unlock()
+
+	delete(p.registrations, shopID)
+}
@@ -0,0 +1,89 @@
+package appserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SignedResponse is a JSON response signed with the shop secret. Shopware expects this for synchronous calls to the
+// app, like payments or tax providers, and verifies the signature before using the response.
+type SignedResponse struct {
+	Body      []byte
+	Signature string
+}
+
+// Write writes the response body and the signature header with status 200.
+func (r SignedResponse) Write(w http.ResponseWriter) error {
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set(AppSignatureKey, r.Signature)
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write(r.Body)
+
+	return err
+}
+
+func signResponse(payload interface{}, secret string) (SignedResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return SignedResponse{}, fmt.Errorf("encode response: %w", err)
+	}
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+
+	return SignedResponse{
+		Body:      body,
+		Signature: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// readSignedRequest verifies the signature of a synchronous call and returns the body, the credentials of the
+// calling shop and the shop secret, which verified the request.
+func (srv *Server) readSignedRequest(req *http.Request) ([]byte, Credentials, string, error) {
+	secret, err := srv.verifyPayloadSignature(req)
+	if err != nil {
+		return nil, Credentials{}, "", err
+	}
+
+	body, err := extractBody(req)
+	if err != nil {
+		return nil, Credentials{}, "", fmt.Errorf("extract body: %w", err)
+	}
+
+	appReq := AppRequest{}
+	if err := json.Unmarshal(body, &appReq); err != nil {
+		return nil, Credentials{}, "", fmt.Errorf("parse body: %w", err)
+	}
+
+	credentials, err := srv.credentialStore.Get(req.Context(), appReq.Source.ShopID)
+	if err != nil {
+		return nil, Credentials{}, "", fmt.Errorf("get shop credentials: %w", err)
+	}
+
+	return body, credentials, secret, nil
+}
+
+// invokeSigned calls a handler of a synchronous call through the middlewares and signs its response with the shop
+// secret, which verified the request. During the grace period of a rotated secret, the shop might not know the new
+// secret yet.
+func (srv *Server) invokeSigned(ctx context.Context, inv Invocation, secret string, h func(ctx context.Context, api *APIClient) (interface{}, error)) (SignedResponse, error) {
+	var resp interface{}
+
+	err := srv.invoke(ctx, inv, func(ctx context.Context, inv Invocation) error {
+		var err error
+		resp, err = h(ctx, inv.API)
+
+		return err
+	})
+	if err != nil {
+		return SignedResponse{}, fmt.Errorf("handler: %w", err)
+	}
+
+	return signResponse(resp, secret)
+}
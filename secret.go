@@ -0,0 +1,66 @@
+package appserver
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// shopSecretLength is the number of random bytes of a shop secret.
+const shopSecretLength = 32
+
+// WithShopSecretGracePeriod sets how long the previous shop secret stays valid after it was rotated, e.g. during a
+// reinstallation of the app. Defaults to 5 minutes.
+func WithShopSecretGracePeriod(gracePeriod time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.secretGracePeriod = gracePeriod
+	}
+}
+
+// setShopSecret sets a new shop secret and keeps the current one as previous secret for the grace period. The
+// secret is only replaced when the shop confirms a new registration, because the registration delivers the new secret
+// to the shop.
+func (srv *Server) setShopSecret(credentials *Credentials, secret string) {
+	if credentials.ShopSecret != "" && srv.secretGracePeriod > 0 {
+		credentials.PreviousShopSecret = credentials.ShopSecret
+		credentials.PreviousShopSecretExpiresAt = time.Now().Add(srv.secretGracePeriod).Unix()
+	} else {
+		credentials.PreviousShopSecret = ""
+		credentials.PreviousShopSecretExpiresAt = 0
+	}
+
+	credentials.ShopSecret = secret
+}
+
+// shopSecrets returns all secrets, which are currently valid for the shop.
+func shopSecrets(credentials Credentials) []string {
+	secrets := []string{credentials.ShopSecret}
+
+	if credentials.PreviousShopSecret != "" && time.Now().Unix() < credentials.PreviousShopSecretExpiresAt {
+		secrets = append(secrets, credentials.PreviousShopSecret)
+	}
+
+	return secrets
+}
+
+// verifyShopSignature verifies the signature with the current and, during the grace period, the previous secret. It
+// returns the secret, which matched, so responses can be signed with the secret the shop still knows.
+func verifyShopSignature(data []byte, signature []byte, credentials Credentials) (string, error) {
+	var err error
+	for _, secret := range shopSecrets(credentials) {
+		if err = verifySignature(data, signature, secret); err == nil {
+			return secret, nil
+		}
+	}
+
+	return "", err
+}
+
+func generateShopSecret() (string, error) {
+	b := make([]byte, shopSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package appserver_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerTestShop registers and confirms the test shop again and returns its new secret.
+func registerTestShop(t *testing.T, srv *appserver.Server) string {
+	t.Helper()
+
+	query := "shop-id=123&shop-url=https://shop.example&timestamp=1234567890"
+	req := httptest.NewRequest(http.MethodGet, "/register?"+query, nil)
+	req.Header.Set(appserver.AppSignatureKey, hex.EncodeToString(appserver.SignTestData("mysecret", query)))
+
+	reg, err := srv.HandleRegistration(req)
+	require.NoError(t, err)
+
+	confirm := `{"apiKey":"apikey","secretKey":"secretkey","timestamp":"1234567891","shopUrl":"https://shop.example","shopId":"123"}`
+	require.NoError(t, srv.HandleConfirm(appserver.NewSignedTestRequest(reg.Secret, confirm)))
+
+	return reg.Secret
+}
+
+func TestServer_ShopSecretRotation(t *testing.T) {
+	payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`
+	handler := func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		return nil
+	}
+
+	t.Run("previous secret is valid during grace period", func(t *testing.T) {
+		srv := newWebhookTestServer(t)
+		srv.Event("product.written", handler)
+
+		secret := registerTestShop(t, srv)
+		assert.Len(t, secret, 43)
+		assert.NotEqual(t, "mysecret", secret)
+
+		assert.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest(secret, payload)))
+		assert.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+		assert.EqualError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("othersecret", payload)), "invalid signature")
+
+		// registering again invalidates the first secret
+		registerTestShop(t, srv)
+		assert.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest(secret, payload)))
+		assert.EqualError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)), "invalid signature")
+	})
+
+	t.Run("without grace period", func(t *testing.T) {
+		srv := newWebhookTestServer(t, appserver.WithShopSecretGracePeriod(0))
+		srv.Event("product.written", handler)
+
+		secret := registerTestShop(t, srv)
+
+		assert.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest(secret, payload)))
+		assert.EqualError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)), "invalid signature")
+	})
+}
+
+func TestServer_ShopSecretRotationSignedResponses(t *testing.T) {
+	srv := newWebhookTestServer(t)
+	srv.TaxProvider("myTaxProvider", func(_ context.Context, _ appserver.TaxProviderRequest, _ *appserver.APIClient) (appserver.TaxProviderResponse, error) {
+		return appserver.TaxProviderResponse{}, nil
+	})
+	srv.CheckoutGateway(func(_ context.Context, _ appserver.CheckoutGatewayRequest, _ *appserver.APIClient) ([]appserver.CheckoutGatewayCommand, error) {
+		return nil, nil
+	})
+	srv.PaymentMethod("syncPayment", appserver.PaymentMethod{
+		Pay: func(_ context.Context, _ appserver.PaymentPayRequest, _ *appserver.APIClient) (appserver.PaymentResponse, error) {
+			return appserver.PaymentResponse{Status: appserver.PaymentStatusPaid}, nil
+		},
+	})
+
+	secret := registerTestShop(t, srv)
+
+	// responses are signed with the secret of the request, the shop might not know the new secret yet
+	for _, requestSecret := range []string{"mysecret", secret} {
+		resp, err := srv.HandleTaxProvider(appserver.NewSignedTestRequest(requestSecret, `{"source":{"shopId":"123"}}`), "myTaxProvider")
+		require.NoError(t, err)
+		assertSignedResponse(t, resp, requestSecret, `{}`)
+
+		resp, err = srv.HandleCheckoutGateway(appserver.NewSignedTestRequest(requestSecret, `{"source":{"shopId":"123"}}`))
+		require.NoError(t, err)
+		assertSignedResponse(t, resp, requestSecret, `[]`)
+
+		payload := `{"source":{"shopId":"123"},"orderTransaction":{"paymentMethod":{"appPaymentMethod":{"identifier":"syncPayment"}}}}`
+		resp, err = srv.HandlePayment(appserver.NewSignedTestRequest(requestSecret, payload), appserver.PaymentOperationPay)
+		require.NoError(t, err)
+		assertSignedResponse(t, resp, requestSecret, `{"status":"paid"}`)
+	}
+}
@@ -1,6 +1,7 @@
 package appserver
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -12,6 +13,8 @@ import (
 const (
 	AppSignatureKey  = "shopware-app-signature"
 	ShopSignatureKey = "shopware-shop-signature"
+
+	ShopwareVersionHeader = "sw-version"
 )
 
 type ServerOpt func(s *Server)
@@ -21,11 +24,35 @@ type Server struct {
 	appName         string
 	appSecret       string
 
-	webhooks map[string]WebhookHandler
-	actions  map[string]ActionHandler
+	webhooks        map[string][]WebhookHandler
+	webhookFallback WebhookHandler
+	actions         map[actionKey]*actionButton
+	paymentMethods  map[string]PaymentMethod
+	taxProviders    map[string]TaxProviderHandler
+	checkoutGateway CheckoutGatewayHandler
+	flowActions     map[string]*flowAction
+	middlewares     []Middleware
+	defaultTimeout  time.Duration
+	eventTimeouts   map[string]time.Duration
+	actionTimeouts  map[actionKey]time.Duration
 
 	credentialStore CredentialStore
 	tokenStore      *tokenStore
+	configCache     *appConfigCache
+	asyncConfig     *AsyncConfig
+	webhookPool     *webhookPool
+	webhookQueue    WebhookQueue
+	queueConfig     QueueConfig
+	queueWorkers    *webhookQueueWorkers
+	seenStore       WebhookSeenStore
+	seenTTL         time.Duration
+	shopURLPolicy   ShopURLChangePolicy
+
+	// pendingRegistrations are registrations of known shops, which aren't confirmed yet
+	pendingRegistrations *pendingRegistrations
+
+	secretGracePeriod time.Duration
+	sessionTTL        time.Duration
 
 	httpClient *http.Client
 }
@@ -37,31 +64,74 @@ type Credentials struct {
 	ShopURL    string `json:"shopUrl" query:"shop-url"`
 	ShopID     string `json:"shopId" query:"shop-id"`
 	ShopSecret string `json:"shopSecret"`
+
+	// PreviousShopSecret is still accepted until PreviousShopSecretExpiresAt (unix time) after the secret was rotated.
+	PreviousShopSecret          string `json:"previousShopSecret,omitempty"`
+	PreviousShopSecretExpiresAt int64  `json:"previousShopSecretExpiresAt,omitempty"`
 }
 
 type Source struct {
 	ShopID     string `json:"shopId"`
 	ShopURL    string `json:"url"`
 	AppVersion string `json:"appVersion"`
+	// EventID identifies a webhook delivery. It's sent by Shopware 6.5 and later.
+	EventID string `json:"eventId"`
+	// Action describes the app action, which caused the request, if sent by the shop. It's passed through as-is,
+	// because its format isn't stable across Shopware versions.
+	Action         json.RawMessage `json:"action,omitempty"`
+	InAppPurchases InAppPurchases  `json:"inAppPurchases"`
+}
+
+// InAppPurchases are the in-app purchases of the shop. Newer Shopware versions send a JWT signed by the Shopware
+// account, older ones a list of purchase identifiers.
+type InAppPurchases struct {
+	Token       string
+	Identifiers []string
+}
+
+func (p *InAppPurchases) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &p.Token); err == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, &p.Identifiers)
 }
 
 type AppRequest struct {
 	Source Source `json:"source"`
+	// ShopwareVersion is the version of the shop sending the request, taken from the sw-version header.
+	ShopwareVersion string `json:"-"`
 }
 
 func NewServer(appName string, appSecret string, confirmationURL string, opts ...ServerOpt) *Server {
 	credentialStore := NewMemoryCredentialStore()
 
 	srv := &Server{
-		webhooks: make(map[string]WebhookHandler),
-		actions:  make(map[string]ActionHandler),
+		webhooks: make(map[string][]WebhookHandler),
+		actions:  make(map[actionKey]*actionButton),
+
+		paymentMethods: make(map[string]PaymentMethod),
+		taxProviders:   make(map[string]TaxProviderHandler),
+		flowActions:    make(map[string]*flowAction),
+
+		eventTimeouts:  make(map[string]time.Duration),
+		actionTimeouts: make(map[actionKey]time.Duration),
 
 		credentialStore: credentialStore,
 		tokenStore:      newTokenStore(),
 
+		pendingRegistrations: newPendingRegistrations(),
+
 		confirmationURL: confirmationURL,
 		appName:         appName,
 		appSecret:       appSecret,
+
+		secretGracePeriod: 5 * time.Minute,
+		sessionTTL:        time.Hour,
 	}
 
 	for _, o := range opts {
@@ -72,6 +142,13 @@ func NewServer(appName string, appSecret string, confirmationURL string, opts ..
 		srv.httpClient = createDefaultHTTPClient()
 	}
 
+	switch {
+	case srv.webhookQueue != nil:
+		srv.queueWorkers = newWebhookQueueWorkers(srv.webhookQueue, srv.queueConfig, srv.dispatchWebhook)
+	case srv.asyncConfig != nil:
+		srv.webhookPool = newWebhookPool(*srv.asyncConfig, srv.dispatchWebhook)
+	}
+
 	return srv
 }
 
@@ -87,12 +164,72 @@ func WithHTTPClient(client *http.Client) ServerOpt {
 	}
 }
 
+// WithAppConfigCache caches the app configuration per shop and sales channel for the given duration. Cached entries
+// of a shop are invalidated, when a system-config.written webhook arrives, so make sure to subscribe to this event in
+// your manifest.xml.
+func WithAppConfigCache(ttl time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.configCache = newAppConfigCache(ttl)
+	}
+}
+
+// Event registers a handler for an event. Multiple handlers can be registered for the same event. The event can also
+// be a pattern ending with ".*" to match all events with that prefix, e.g. "product.*", or "*" to match all events.
+//
+// Handlers of exact matches are called first, followed by handlers of patterns from the most to the least specific
+// one. Handlers of the same event or pattern are called in the order they were registered.
 func (srv *Server) Event(event string, handler WebhookHandler) {
-	srv.webhooks[event] = handler
+	srv.webhooks[event] = append(srv.webhooks[event], handler)
 }
 
-func (srv *Server) Action(entity string, action string, handler ActionHandler) {
-	srv.actions[entity+action] = handler
+// EventFallback registers a handler, which is called for all events without a registered handler.
+func (srv *Server) EventFallback(handler WebhookHandler) {
+	srv.webhookFallback = handler
+}
+
+func (srv *Server) Action(entity string, action string, handler ActionHandler, opts ...ActionOpt) {
+	b := &actionButton{handler: handler, view: ActionViewDetail}
+	for _, o := range opts {
+		o(b)
+	}
+
+	srv.actions[actionKey{entity: entity, action: action}] = b
+}
+
+// WithHandlerTimeout sets the timeout for the context of all webhook and action handlers. Shopware cancels webhook
+// requests after a few seconds, so long-running handlers should be avoided anyway.
+func WithHandlerTimeout(timeout time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.defaultTimeout = timeout
+	}
+}
+
+// WithEventTimeout sets the timeout for the context of the handlers of an event, overriding WithHandlerTimeout.
+func WithEventTimeout(event string, timeout time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.eventTimeouts[event] = timeout
+	}
+}
+
+// WithActionTimeout sets the timeout for the context of an action handler, overriding WithHandlerTimeout.
+func WithActionTimeout(entity string, action string, timeout time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.actionTimeouts[actionKey{entity: entity, action: action}] = timeout
+	}
+}
+
+// InvalidateAppConfig removes the cached app configuration of a shop, so it's fetched again on the next access.
+func (srv *Server) InvalidateAppConfig(shopID string) {
+	if srv.configCache != nil {
+		srv.configCache.Invalidate(shopID)
+	}
+}
+
+func (srv *Server) newAPIClient(credentials Credentials) *APIClient {
+	api := newAPIClient(srv.httpClient, srv.appName, credentials, srv.tokenStore)
+	api.configCache = srv.configCache
+
+	return api
 }
 
 func extractBody(req *http.Request) ([]byte, error) {
@@ -0,0 +1,182 @@
+package appserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSessionTokenMissing = errors.New("missing session token")
+	ErrSessionTokenExpired = errors.New("session token expired")
+)
+
+// sessionTokenAudience is the audience of session tokens. Together with the derived signing key, it keeps session
+// tokens and other tokens signed for the shop, like storefront tokens, from being used in place of each other.
+const sessionTokenAudience = "appserver-module-session"
+
+type contextKey int
+
+const (
+	sessionContextKey contextKey = iota
+	apiClientContextKey
+	storefrontClaimsContextKey
+)
+
+// Session is the content of a session token, which is issued to an admin module page. See ModuleRequest.SessionToken.
+type Session struct {
+	ShopID          string `json:"sub"`
+	UserLanguage    string `json:"userLanguage,omitempty"`
+	ContextLanguage string `json:"contextLanguage,omitempty"`
+	// IssuedAt and ExpiresAt are unix times.
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// WithModuleSessionTTL sets how long session tokens of admin module pages are valid. Defaults to 1 hour.
+func WithModuleSessionTTL(ttl time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.sessionTTL = ttl
+	}
+}
+
+// sessionClaims are the claims of a session token.
+type sessionClaims struct {
+	Session
+	Audience string `json:"aud"`
+}
+
+// sessionKey derives the key of session tokens from the shop secret. The shop signs its own tokens, e.g. storefront
+// tokens, with the shop secret, so it must not be used for session tokens directly.
+func sessionKey(shopSecret string) string {
+	h := hmac.New(sha256.New, []byte(shopSecret))
+	h.Write([]byte("session"))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newSessionToken issues a session token for the module page, signed with a key derived from the shop secret.
+func (srv *Server) newSessionToken(module ModuleRequest, credentials Credentials) (string, error) {
+	now := time.Now()
+
+	return signJWT(sessionClaims{
+		Session: Session{
+			ShopID:          module.ShopID,
+			UserLanguage:    module.UserLanguage,
+			ContextLanguage: module.ContextLanguage,
+			IssuedAt:        now.Unix(),
+			ExpiresAt:       now.Add(srv.sessionTTL).Unix(),
+		},
+		Audience: sessionTokenAudience,
+	}, sessionKey(credentials.ShopSecret))
+}
+
+// VerifySessionToken verifies a session token issued to an admin module page and returns its session.
+func (srv *Server) VerifySessionToken(ctx context.Context, token string) (Session, error) {
+	session, _, err := srv.verifySessionToken(ctx, token)
+
+	return session, err
+}
+
+func (srv *Server) verifySessionToken(ctx context.Context, token string) (Session, Credentials, error) {
+	if token == "" {
+		return Session{}, Credentials{}, ErrSessionTokenMissing
+	}
+
+	jwt, err := parseJWT(token)
+	if err != nil {
+		return Session{}, Credentials{}, SignatureVerificationError{err: err}
+	}
+
+	claims := sessionClaims{}
+	if err := jwt.decodeClaims(&claims); err != nil {
+		return Session{}, Credentials{}, SignatureVerificationError{err: err}
+	}
+
+	credentials, err := srv.credentialStore.Get(ctx, claims.ShopID)
+	if err != nil {
+		return Session{}, Credentials{}, SignatureVerificationError{err: fmt.Errorf("get shop credentials: %w", err)}
+	}
+
+	// the token might be issued before the shop secret was rotated
+	for _, secret := range shopSecrets(credentials) {
+		if err = verifySignature(jwt.signingInput, jwt.signature, sessionKey(secret)); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return Session{}, Credentials{}, SignatureVerificationError{err: err}
+	}
+
+	if claims.Audience != sessionTokenAudience {
+		return Session{}, Credentials{}, SignatureVerificationError{err: fmt.Errorf("invalid audience: %s", claims.Audience)}
+	}
+
+	if !time.Now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return Session{}, Credentials{}, ErrSessionTokenExpired
+	}
+
+	return claims.Session, credentials, nil
+}
+
+// SessionMiddleware authenticates requests of admin module pages with the session token in the Authorization header,
+// e.g. "Authorization: Bearer <token>". It responds with 401, if the header doesn't use the Bearer scheme or the token
+// is invalid. Otherwise, the session and an API client for the shop are available through SessionFromContext and
+// APIClientFromContext.
+func (srv *Server) SessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req.Header.Get("Authorization"))
+		if !ok {
+			http.Error(w, ErrSessionTokenMissing.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		session, credentials, err := srv.verifySessionToken(req.Context(), token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		api := srv.newAPIClient(credentials)
+		if session.ContextLanguage != "" {
+			api = api.With(RequestLanguageID(session.ContextLanguage))
+		}
+
+		ctx := context.WithValue(req.Context(), sessionContextKey, session)
+		ctx = context.WithValue(ctx, apiClientContextKey, api)
+
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// bearerToken returns the token of an Authorization header with the Bearer scheme. The scheme is case-insensitive.
+func bearerToken(authorization string) (string, bool) {
+	const prefix = "Bearer "
+	if len(authorization) < len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return authorization[len(prefix):], true
+}
+
+// SessionFromContext returns the session of a request authenticated by SessionMiddleware.
+func SessionFromContext(ctx context.Context) (Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(Session)
+
+	return session, ok
+}
+
+// APIClientFromContext returns the API client of the shop of a request authenticated by SessionMiddleware or
+// StorefrontMiddleware.
+func APIClientFromContext(ctx context.Context) (*APIClient, bool) {
+	api, ok := ctx.Value(apiClientContextKey).(*APIClient)
+
+	return api, ok
+}
@@ -0,0 +1,133 @@
+package appserver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issueTestSessionToken loads a module page and returns the session token passed to it.
+func issueTestSessionToken(t *testing.T, srv *appserver.Server) string {
+	t.Helper()
+
+	var token string
+	handler := srv.Module(func(_ http.ResponseWriter, _ *http.Request, module appserver.ModuleRequest, _ *appserver.APIClient) {
+		token = module.SessionToken
+	})
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	query := appserver.SignTestQuery("mysecret", "shop-id=123&shop-url=https://shop.example&timestamp="+timestamp+"&sw-user-language=de-DE")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/module?"+query, nil))
+	require.NotEmpty(t, token)
+
+	return token
+}
+
+func TestServer_SessionMiddleware(t *testing.T) {
+	store := newTestCredentialStore(t)
+	srv := newWebhookTestServer(t, appserver.WithCredentialStore(store))
+	token := issueTestSessionToken(t, srv)
+
+	var session appserver.Session
+	handler := srv.SessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		session, ok = appserver.SessionFromContext(r.Context())
+		assert.True(t, ok)
+
+		api, ok := appserver.APIClientFromContext(r.Context())
+		assert.True(t, ok)
+		assert.NotNil(t, api)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, "/module/api/orders", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve("Bearer "+token))
+	assert.Equal(t, "123", session.ShopID)
+	assert.Equal(t, "de-DE", session.UserLanguage)
+
+	assert.Equal(t, http.StatusNoContent, serve("bearer "+token))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(""))
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer invalid"))
+	assert.Equal(t, http.StatusUnauthorized, serve(token))
+	assert.Equal(t, http.StatusUnauthorized, serve("Basic "+token))
+	assert.Equal(t, http.StatusUnauthorized, serve("Token "+token))
+
+	// modified claims must invalidate the signature
+	parts := strings.Split(token, ".")
+	claims := parts[1][:len(parts[1])-1] + "A"
+	if claims == parts[1] {
+		claims = parts[1][:len(parts[1])-1] + "B"
+	}
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer "+parts[0]+"."+claims+"."+parts[2]))
+
+	// tokens of a reinstalled shop are rejected once the grace period of the old secret is over
+	require.NoError(t, store.Store(context.Background(), appserver.Credentials{ShopID: "123", ShopSecret: "othersecret"}))
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer "+token))
+}
+
+func TestServer_VerifySessionToken(t *testing.T) {
+	srv := newWebhookTestServer(t, appserver.WithModuleSessionTTL(time.Nanosecond))
+	token := issueTestSessionToken(t, srv)
+
+	_, err := srv.VerifySessionToken(context.Background(), token)
+	assert.ErrorIs(t, err, appserver.ErrSessionTokenExpired)
+
+	_, err = srv.VerifySessionToken(context.Background(), "")
+	assert.ErrorIs(t, err, appserver.ErrSessionTokenMissing)
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{
+			// e.g. a storefront token of the shop
+			name:  "signed with the shop secret",
+			token: newTestStorefrontToken("HS256", fmt.Sprintf(`{"sub":"123","aud":"appserver-module-session","exp":%d}`, exp), "mysecret"),
+		},
+		{
+			name:  "missing audience",
+			token: newTestStorefrontToken("HS256", fmt.Sprintf(`{"sub":"123","exp":%d}`, exp), appserver.SessionKey("mysecret")),
+		},
+		{
+			name:  "other audience",
+			token: newTestStorefrontToken("HS256", fmt.Sprintf(`{"sub":"123","aud":"other","exp":%d}`, exp), appserver.SessionKey("mysecret")),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := srv.VerifySessionToken(context.Background(), tt.token)
+			assert.ErrorAs(t, err, &appserver.SignatureVerificationError{})
+		})
+	}
+
+	valid := newTestStorefrontToken("HS256", fmt.Sprintf(`{"sub":"123","aud":"appserver-module-session","exp":%d}`, exp), appserver.SessionKey("mysecret"))
+	session, err := srv.VerifySessionToken(context.Background(), valid)
+	require.NoError(t, err)
+	assert.Equal(t, "123", session.ShopID)
+}
@@ -0,0 +1,81 @@
+package appserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ShopURLDecision tells the server how to handle a changed shop URL, see WithShopURLChangePolicy.
+type ShopURLDecision int
+
+const (
+	// ShopURLKeep keeps the stored URL and processes the request.
+	ShopURLKeep ShopURLDecision = iota
+	// ShopURLUpdate stores the new URL and processes the request.
+	ShopURLUpdate
+	// ShopURLReject rejects the request with a ShopURLMismatchError.
+	ShopURLReject
+)
+
+// ShopURLChangePolicy decides what happens, if a signed request contains a different shop URL than the stored
+// credentials. It can also be used to get notified about the change, e.g. for logging.
+type ShopURLChangePolicy func(ctx context.Context, credentials Credentials, newURL string) ShopURLDecision
+
+// UpdateShopURL is a ShopURLChangePolicy, which always updates the stored URL.
+func UpdateShopURL(context.Context, Credentials, string) ShopURLDecision {
+	return ShopURLUpdate
+}
+
+// RejectShopURLChange is a ShopURLChangePolicy, which rejects all requests with a changed URL. The shop has to be
+// registered again to update the URL.
+func RejectShopURLChange(context.Context, Credentials, string) ShopURLDecision {
+	return ShopURLReject
+}
+
+// WithShopURLChangePolicy sets the policy for requests, which contain a different shop URL than the stored
+// credentials. Without a policy, the stored URL is kept.
+func WithShopURLChangePolicy(policy ShopURLChangePolicy) ServerOpt {
+	return func(s *Server) {
+		s.shopURLPolicy = policy
+	}
+}
+
+type ShopURLMismatchError struct {
+	ShopID     string
+	StoredURL  string
+	RequestURL string
+}
+
+func (e ShopURLMismatchError) Error() string {
+	return fmt.Sprintf("shop url of shop %s changed from %s to %s", e.ShopID, e.StoredURL, e.RequestURL)
+}
+
+// reconcileShopURL applies the shop URL change policy, if the URL of a verified request differs from the stored one.
+func (srv *Server) reconcileShopURL(ctx context.Context, credentials Credentials, requestURL string) error {
+	if srv.shopURLPolicy == nil || requestURL == "" || normalizeShopURL(requestURL) == normalizeShopURL(credentials.ShopURL) {
+		return nil
+	}
+
+	switch srv.shopURLPolicy(ctx, credentials, requestURL) {
+	case ShopURLUpdate:
+		credentials.ShopURL = requestURL
+		if err := srv.credentialStore.Store(ctx, credentials); err != nil {
+			return fmt.Errorf("store shop credentials: %w", err)
+		}
+
+		// tokens are issued by the old URL
+		srv.tokenStore.Delete(credentials.ShopID)
+
+		return nil
+	case ShopURLReject:
+		return ShopURLMismatchError{ShopID: credentials.ShopID, StoredURL: credentials.ShopURL, RequestURL: requestURL}
+	case ShopURLKeep:
+	}
+
+	return nil
+}
+
+func normalizeShopURL(shopURL string) string {
+	return strings.TrimSuffix(shopURL, "/")
+}
@@ -0,0 +1,176 @@
+package appserver_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ShopURLChangePolicy(t *testing.T) {
+	payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123","url":"https://new.example.com"}}`
+
+	tests := []struct {
+		name        string
+		policy      appserver.ShopURLChangePolicy
+		expectedURL string
+		expectedErr bool
+	}{
+		{name: "without policy", policy: nil, expectedURL: "https://old.example.com"},
+		{name: "update", policy: appserver.UpdateShopURL, expectedURL: "https://new.example.com"},
+		{name: "reject", policy: appserver.RejectShopURLChange, expectedURL: "https://old.example.com", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			store := appserver.NewMemoryCredentialStore()
+			require.NoError(t, store.Store(context.Background(), appserver.Credentials{
+				ShopID:     "123",
+				ShopURL:    "https://old.example.com",
+				ShopSecret: "mysecret",
+			}))
+
+			opts := []appserver.ServerOpt{appserver.WithCredentialStore(store)}
+			if tt.policy != nil {
+				opts = append(opts, appserver.WithShopURLChangePolicy(tt.policy))
+			}
+
+			srv := appserver.NewServer("", "mysecret", "", opts...)
+			srv.Event("product.written", func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+				return nil
+			})
+
+			err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+			if tt.expectedErr {
+				var mismatchErr appserver.ShopURLMismatchError
+				if assert.ErrorAs(t, err, &mismatchErr) {
+					assert.Equal(t, "https://new.example.com", mismatchErr.RequestURL)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			credentials, err := store.Get(context.Background(), "123")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedURL, credentials.ShopURL)
+		})
+	}
+}
+
+func TestServer_HandleRegistrationOfKnownShop(t *testing.T) {
+	ctx := context.Background()
+	stored := appserver.Credentials{
+		ShopID:     "123",
+		ShopURL:    "https://old.example.com",
+		ShopSecret: "oldsecret",
+		APIKey:     "apikey",
+		SecretKey:  "secretkey",
+	}
+
+	register := func(t *testing.T, srv *appserver.Server, shopURL string) string {
+		t.Helper()
+
+		query := "shop-id=123&shop-url=" + shopURL + "&timestamp=1234567890"
+		req := httptest.NewRequest(http.MethodGet, "/register?"+query, nil)
+		req.Header.Set(appserver.AppSignatureKey, hex.EncodeToString(appserver.SignTestData("appsecret", query)))
+
+		reg, err := srv.HandleRegistration(req)
+		require.NoError(t, err)
+
+		return reg.Secret
+	}
+
+	t.Run("credentials are kept until the shop confirms with the new secret", func(t *testing.T) {
+		store := appserver.NewMemoryCredentialStore()
+		require.NoError(t, store.Store(ctx, stored))
+		srv := appserver.NewServer("MyApp", "appsecret", "https://app.example.com/confirm", appserver.WithCredentialStore(store))
+
+		secret := register(t, srv, "https://attacker.example.com")
+
+		credentials, err := store.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, stored, credentials)
+
+		confirm := `{"apiKey":"newapikey","secretKey":"newsecretkey","shopUrl":"https://attacker.example.com","shopId":"123"}`
+		for _, wrongSecret := range []string{"oldsecret", "appsecret", "othersecret"} {
+			var verificationErr appserver.SignatureVerificationError
+			assert.ErrorAs(t, srv.HandleConfirm(appserver.NewSignedTestRequest(wrongSecret, confirm)), &verificationErr)
+		}
+
+		credentials, err = store.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, stored, credentials)
+
+		require.NoError(t, srv.HandleConfirm(appserver.NewSignedTestRequest(secret, confirm)))
+
+		credentials, err = store.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, "https://attacker.example.com", credentials.ShopURL)
+		assert.Equal(t, secret, credentials.ShopSecret)
+		assert.Equal(t, "oldsecret", credentials.PreviousShopSecret)
+		assert.Equal(t, "newapikey", credentials.APIKey)
+		assert.Equal(t, "newsecretkey", credentials.SecretKey)
+
+		// the registration can only be confirmed once
+		assert.Error(t, srv.HandleConfirm(appserver.NewSignedTestRequest("oldsecret", confirm)))
+	})
+
+	tests := []struct {
+		name              string
+		shopURL           string
+		expectedAPIKey    string
+		expectedSecretKey string
+	}{
+		{name: "api keys are kept if the url is unchanged", shopURL: "https://old.example.com/", expectedAPIKey: "apikey", expectedSecretKey: "secretkey"},
+		{name: "api keys are dropped if the url changed", shopURL: "https://new.example.com"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			store := appserver.NewMemoryCredentialStore()
+			require.NoError(t, store.Store(ctx, stored))
+			srv := appserver.NewServer("MyApp", "appsecret", "https://app.example.com/confirm", appserver.WithCredentialStore(store))
+
+			secret := register(t, srv, tt.shopURL)
+			require.NoError(t, srv.HandleConfirm(appserver.NewSignedTestRequest(secret, `{"shopId":"123"}`)))
+
+			credentials, err := store.Get(ctx, "123")
+			require.NoError(t, err)
+			assert.Equal(t, tt.shopURL, credentials.ShopURL)
+			assert.Equal(t, tt.expectedAPIKey, credentials.APIKey)
+			assert.Equal(t, tt.expectedSecretKey, credentials.SecretKey)
+		})
+	}
+}
+
+func TestServer_HandleConfirm(t *testing.T) {
+	ctx := context.Background()
+	store := appserver.NewMemoryCredentialStore()
+	srv := appserver.NewServer("MyApp", "appsecret", "https://app.example.com/confirm", appserver.WithCredentialStore(store))
+
+	query := "shop-id=123&shop-url=https://shop.example&timestamp=1234567890"
+	req := httptest.NewRequest(http.MethodGet, "/register?"+query, nil)
+	req.Header.Set(appserver.AppSignatureKey, hex.EncodeToString(appserver.SignTestData("appsecret", query)))
+
+	reg, err := srv.HandleRegistration(req)
+	require.NoError(t, err)
+
+	confirm := `{"apiKey":"apikey","secretKey":"secretkey","shopUrl":"https://shop.example","shopId":"123"}`
+	var verificationErr appserver.SignatureVerificationError
+	assert.ErrorAs(t, srv.HandleConfirm(appserver.NewSignedTestRequest("appsecret", confirm)), &verificationErr)
+
+	require.NoError(t, srv.HandleConfirm(appserver.NewSignedTestRequest(reg.Secret, confirm)))
+
+	credentials, err := store.Get(ctx, "123")
+	require.NoError(t, err)
+	assert.Equal(t, reg.Secret, credentials.ShopSecret)
+	assert.Equal(t, "apikey", credentials.APIKey)
+	assert.Equal(t, "secretkey", credentials.SecretKey)
+}
@@ -26,14 +26,15 @@ func (e SignatureVerificationError) Unwrap() error {
 	return e.err
 }
 
-func (srv *Server) verifyPayloadSignature(req *http.Request) error {
+// verifyPayloadSignature verifies the signature of the request body and returns the shop secret, which matched.
+func (srv *Server) verifyPayloadSignature(req *http.Request) (string, error) {
 	body, err := extractBody(req)
 	if err != nil {
-		return SignatureVerificationError{err: fmt.Errorf("extract request body: %w", err)}
+		return "", SignatureVerificationError{err: fmt.Errorf("extract request body: %w", err)}
 	}
 
 	if len(body) == 0 {
-		return SignatureVerificationError{err: errors.New("empty payload")}
+		return "", SignatureVerificationError{err: errors.New("empty payload")}
 	}
 
 	// copy body back to the request
@@ -41,44 +42,45 @@ func (srv *Server) verifyPayloadSignature(req *http.Request) error {
 
 	appReq := AppRequest{}
 	if err := json.Unmarshal(body, &appReq); err != nil {
-		return SignatureVerificationError{err: fmt.Errorf("parse body: %w", err)}
+		return "", SignatureVerificationError{err: fmt.Errorf("parse body: %w", err)}
 	}
 
 	credentials, err := srv.credentialStore.Get(req.Context(), appReq.Source.ShopID)
 	if err != nil {
-		return SignatureVerificationError{err: fmt.Errorf("get shop credentials: %w", err)}
+		return "", SignatureVerificationError{err: fmt.Errorf("get shop credentials: %w", err)}
 	}
 
 	signature, err := hex.DecodeString(req.Header.Get(ShopSignatureKey))
 	if err != nil {
-		return SignatureVerificationError{err: fmt.Errorf("decode signature: %w", err)}
+		return "", SignatureVerificationError{err: fmt.Errorf("decode signature: %w", err)}
 	}
 
-	if err := verifySignature(body, signature, credentials.ShopSecret); err != nil {
-		return SignatureVerificationError{err: err}
+	secret, err := verifyShopSignature(body, signature, credentials)
+	if err != nil {
+		return "", SignatureVerificationError{err: err}
 	}
 
-	return nil
+	if err := srv.reconcileShopURL(req.Context(), credentials, appReq.Source.ShopURL); err != nil {
+		return "", err
+	}
+
+	return secret, nil
 }
 
 func (srv *Server) verifyQuerySignature(req *http.Request) error {
-	shopID := req.URL.Query().Get("shop-id")
+	shopID := req.URL.Query().Get(QueryShopID)
 	if shopID == "" {
 		return SignatureVerificationError{err: errors.New("missing query parameter: shop-id")}
 	}
 
-	signature, err := hex.DecodeString(req.URL.Query().Get(ShopSignatureKey))
+	query, rawSignature, err := canonicalQuery(req.URL.RawQuery)
 	if err != nil {
-		return SignatureVerificationError{err: fmt.Errorf("decode signature: %w", err)}
+		return SignatureVerificationError{err: err}
 	}
 
-	// Go sorts the query internally by key, so it's hard to replicate Shopware's behaviour here.
-	// The signature needs be calculated on the raw query string, because that's what Shopware does and expects. The
-	// tests in Shopware indicate, that the signature key/value should just be replaced with an empty string. It works.
-	query := strings.ReplaceAll(req.URL.RawQuery, fmt.Sprintf("&%s=%s", ShopSignatureKey, req.URL.Query().Get(ShopSignatureKey)), "")
-	query, err = url.QueryUnescape(query)
+	signature, err := hex.DecodeString(rawSignature)
 	if err != nil {
-		return SignatureVerificationError{err: fmt.Errorf("encode query: %w", err)}
+		return SignatureVerificationError{err: fmt.Errorf("decode signature: %w", err)}
 	}
 
 	credentials, err := srv.credentialStore.Get(req.Context(), shopID)
@@ -86,11 +88,48 @@ func (srv *Server) verifyQuerySignature(req *http.Request) error {
 		return SignatureVerificationError{err: fmt.Errorf("get shop credentials: %w", err)}
 	}
 
-	if err := verifySignature([]byte(query), signature, credentials.ShopSecret); err != nil {
+	if _, err := verifyShopSignature([]byte(query), signature, credentials); err != nil {
 		return SignatureVerificationError{err: err}
 	}
 
-	return nil
+	return srv.reconcileShopURL(req.Context(), credentials, req.URL.Query().Get(QueryShopURL))
+}
+
+// canonicalQuery removes the signature parameter from the raw query and returns the remaining query, as signed by
+// Shopware, and the signature. Go sorts parsed queries by key, so the raw query is used to keep the order and encoding
+// of all other parameters.
+func canonicalQuery(rawQuery string) (string, string, error) {
+	params := strings.Split(rawQuery, "&")
+	kept := make([]string, 0, len(params))
+
+	signature := ""
+	found := false
+	for _, param := range params {
+		key, value, _ := strings.Cut(param, "=")
+
+		if unescaped, err := url.QueryUnescape(key); err != nil || unescaped != ShopSignatureKey {
+			kept = append(kept, param)
+			continue
+		}
+
+		if found {
+			return "", "", errors.New("duplicate signature")
+		}
+
+		var err error
+		signature, err = url.QueryUnescape(value)
+		if err != nil {
+			return "", "", fmt.Errorf("decode signature: %w", err)
+		}
+
+		found = true
+	}
+
+	if !found {
+		return "", "", errors.New("missing signature")
+	}
+
+	return strings.Join(kept, "&"), signature, nil
 }
 
 func verifySignature(data []byte, signature []byte, key string) error {
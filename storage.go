@@ -42,3 +42,9 @@ func (s *tokenStore) Store(shopID string, token *oauth2.Token) {
 	defer s.accessTokensMu.Unlock()
 	s.accessTokens[shopID] = token
 }
+
+func (s *tokenStore) Delete(shopID string) {
+	s.accessTokensMu.Lock()
+	defer s.accessTokensMu.Unlock()
+	delete(s.accessTokens, shopID)
+}
@@ -0,0 +1,152 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StorefrontTokenHeader contains the token of requests sent from the storefront to the app, e.g. by an app script.
+const StorefrontTokenHeader = "shopware-app-token"
+
+// storefrontTokenLeeway compensates for clock differences between the shop and the app server.
+const storefrontTokenLeeway = time.Minute
+
+var (
+	ErrStorefrontTokenMissing       = errors.New("missing storefront token")
+	ErrStorefrontTokenExpired       = errors.New("storefront token expired")
+	ErrStorefrontTokenNotYetValid   = errors.New("storefront token not yet valid")
+	ErrStorefrontTokenMissingIssuer = errors.New("storefront token without issuer")
+)
+
+// StorefrontClaims are the claims of a token generated by the store API route /store-api/app-system/{name}/generate-token.
+// They describe the sales channel context of the customer.
+type StorefrontClaims struct {
+	// ShopID is the issuer of the token.
+	ShopID string `json:"iss"`
+	// IssuedAt, NotBefore and ExpiresAt are unix times.
+	IssuedAt  int64 `json:"iat"`
+	NotBefore int64 `json:"nbf"`
+	ExpiresAt int64 `json:"exp"`
+
+	// CustomerID is empty for guests, who aren't logged in.
+	CustomerID       string `json:"customerId,omitempty"`
+	CustomerGroupID  string `json:"customerGroupId"`
+	SalesChannelID   string `json:"salesChannelId"`
+	LanguageID       string `json:"languageId"`
+	CurrencyID       string `json:"currencyId"`
+	CountryID        string `json:"countryId"`
+	PaymentMethodID  string `json:"paymentMethodId"`
+	ShippingMethodID string `json:"shippingMethodId"`
+}
+
+func (c *StorefrontClaims) UnmarshalJSON(data []byte) error {
+	type storefrontClaims StorefrontClaims
+
+	// Shopware sends the dates with fractional seconds, if there are any
+	raw := struct {
+		*storefrontClaims
+
+		IssuedAt  float64 `json:"iat"`
+		NotBefore float64 `json:"nbf"`
+		ExpiresAt float64 `json:"exp"`
+	}{storefrontClaims: (*storefrontClaims)(c)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	c.IssuedAt = int64(raw.IssuedAt)
+	c.NotBefore = int64(raw.NotBefore)
+	c.ExpiresAt = int64(raw.ExpiresAt)
+
+	return nil
+}
+
+// LoggedIn reports whether the token was generated for a logged-in customer.
+func (c StorefrontClaims) LoggedIn() bool {
+	return c.CustomerID != ""
+}
+
+// VerifyStorefrontRequest verifies the token in the shopware-app-token header of a request sent from the storefront
+// and returns its claims. The token is signed with the shop secret.
+func (srv *Server) VerifyStorefrontRequest(req *http.Request) (StorefrontClaims, error) {
+	claims, _, err := srv.verifyStorefrontToken(req.Context(), req.Header.Get(StorefrontTokenHeader))
+
+	return claims, err
+}
+
+func (srv *Server) verifyStorefrontToken(ctx context.Context, token string) (StorefrontClaims, Credentials, error) {
+	if token == "" {
+		return StorefrontClaims{}, Credentials{}, ErrStorefrontTokenMissing
+	}
+
+	jwt, err := parseJWT(token)
+	if err != nil {
+		return StorefrontClaims{}, Credentials{}, SignatureVerificationError{err: err}
+	}
+
+	claims := StorefrontClaims{}
+	if err := jwt.decodeClaims(&claims); err != nil {
+		return StorefrontClaims{}, Credentials{}, SignatureVerificationError{err: err}
+	}
+
+	if claims.ShopID == "" {
+		return StorefrontClaims{}, Credentials{}, ErrStorefrontTokenMissingIssuer
+	}
+
+	credentials, err := srv.credentialStore.Get(ctx, claims.ShopID)
+	if err != nil {
+		return StorefrontClaims{}, Credentials{}, SignatureVerificationError{err: fmt.Errorf("get shop credentials: %w", err)}
+	}
+
+	if _, err := verifyShopSignature(jwt.signingInput, jwt.signature, credentials); err != nil {
+		return StorefrontClaims{}, Credentials{}, SignatureVerificationError{err: err}
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.Add(-storefrontTokenLeeway).After(time.Unix(claims.ExpiresAt, 0)) {
+		return StorefrontClaims{}, Credentials{}, ErrStorefrontTokenExpired
+	}
+
+	if claims.NotBefore != 0 && now.Add(storefrontTokenLeeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return StorefrontClaims{}, Credentials{}, ErrStorefrontTokenNotYetValid
+	}
+
+	return claims, credentials, nil
+}
+
+// StorefrontMiddleware authenticates requests sent from the storefront with the token in the shopware-app-token
+// header. It responds with 401, if the token is invalid. Otherwise, the claims and an API client for the shop are
+// available through StorefrontClaimsFromContext and APIClientFromContext.
+//
+// There is no built-in router, so wrap the handlers of your storefront endpoints, e.g. with http.ServeMux.
+func (srv *Server) StorefrontMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claims, credentials, err := srv.verifyStorefrontToken(req.Context(), req.Header.Get(StorefrontTokenHeader))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		api := srv.newAPIClient(credentials)
+		if claims.LanguageID != "" {
+			api = api.With(RequestLanguageID(claims.LanguageID))
+		}
+
+		ctx := context.WithValue(req.Context(), storefrontClaimsContextKey, claims)
+		ctx = context.WithValue(ctx, apiClientContextKey, api)
+
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// StorefrontClaimsFromContext returns the claims of a request authenticated by StorefrontMiddleware.
+func StorefrontClaimsFromContext(ctx context.Context) (StorefrontClaims, bool) {
+	claims, ok := ctx.Value(storefrontClaimsContextKey).(StorefrontClaims)
+
+	return claims, ok
+}
@@ -0,0 +1,144 @@
+package appserver_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorefrontToken creates a token the way Shopware does in /store-api/app-system/{name}/generate-token.
+func newTestStorefrontToken(alg string, claims string, secret string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"` + alg + `"}`))
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(appserver.SignTestData(secret, signingInput))
+}
+
+func newTestStorefrontClaims(issuedAt time.Time) string {
+	return fmt.Sprintf(`{"iss":"123","iat":%d.123456,"nbf":%d.123456,"exp":%d.123456,`+
+		`"customerId":"c1","customerGroupId":"g1","salesChannelId":"s1","languageId":"l1","currencyId":"cur1",`+
+		`"countryId":"co1","paymentMethodId":"p1","shippingMethodId":"sh1"}`,
+		issuedAt.Unix(), issuedAt.Unix(), issuedAt.Add(10*time.Minute).Unix())
+}
+
+func TestServer_VerifyStorefrontRequest(t *testing.T) {
+	srv := newWebhookTestServer(t)
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+		// invalidSignature expects a SignatureVerificationError instead of err
+		invalidSignature bool
+	}{
+		{
+			name:  "valid token",
+			token: newTestStorefrontToken("HS256", newTestStorefrontClaims(now), "mysecret"),
+		},
+		{
+			name:  "missing token",
+			token: "",
+			err:   appserver.ErrStorefrontTokenMissing,
+		},
+		{
+			name:  "expired token",
+			token: newTestStorefrontToken("HS256", newTestStorefrontClaims(now.Add(-time.Hour)), "mysecret"),
+			err:   appserver.ErrStorefrontTokenExpired,
+		},
+		{
+			name:  "token of the future",
+			token: newTestStorefrontToken("HS256", newTestStorefrontClaims(now.Add(time.Hour)), "mysecret"),
+			err:   appserver.ErrStorefrontTokenNotYetValid,
+		},
+		{
+			name:  "missing issuer",
+			token: newTestStorefrontToken("HS256", `{"exp":9999999999}`, "mysecret"),
+			err:   appserver.ErrStorefrontTokenMissingIssuer,
+		},
+		{
+			name:             "wrong secret",
+			token:            newTestStorefrontToken("HS256", newTestStorefrontClaims(now), "wrongsecret"),
+			invalidSignature: true,
+		},
+		{
+			name:             "unsupported algorithm",
+			token:            newTestStorefrontToken("none", newTestStorefrontClaims(now), "mysecret"),
+			invalidSignature: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/storefront/wishlist", nil)
+			if tt.token != "" {
+				req.Header.Set(appserver.StorefrontTokenHeader, tt.token)
+			}
+
+			claims, err := srv.VerifyStorefrontRequest(req)
+
+			switch {
+			case tt.invalidSignature:
+				assert.ErrorAs(t, err, &appserver.SignatureVerificationError{})
+			case tt.err != nil:
+				assert.ErrorIs(t, err, tt.err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, appserver.StorefrontClaims{
+					ShopID:           "123",
+					IssuedAt:         now.Unix(),
+					NotBefore:        now.Unix(),
+					ExpiresAt:        now.Add(10 * time.Minute).Unix(),
+					CustomerID:       "c1",
+					CustomerGroupID:  "g1",
+					SalesChannelID:   "s1",
+					LanguageID:       "l1",
+					CurrencyID:       "cur1",
+					CountryID:        "co1",
+					PaymentMethodID:  "p1",
+					ShippingMethodID: "sh1",
+				}, claims)
+				assert.True(t, claims.LoggedIn())
+			}
+		})
+	}
+}
+
+func TestServer_StorefrontMiddleware(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	handler := srv.StorefrontMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := appserver.StorefrontClaimsFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "s1", claims.SalesChannelID)
+
+		api, ok := appserver.APIClientFromContext(r.Context())
+		assert.True(t, ok)
+		assert.NotNil(t, api)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/storefront/wishlist", nil)
+	req.Header.Set(appserver.StorefrontTokenHeader, newTestStorefrontToken("HS256", newTestStorefrontClaims(time.Now()), "mysecret"))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/storefront/wishlist", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	_, ok := appserver.StorefrontClaimsFromContext(context.Background())
+	assert.False(t, ok)
+}
@@ -0,0 +1,75 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const InvocationTypeTaxProvider = "tax"
+
+type TaxProviderNotFoundError struct {
+	identifier string
+}
+
+func (e TaxProviderNotFoundError) Error() string {
+	return fmt.Sprintf("no tax provider found for identifier: %s", e.identifier)
+}
+
+// TaxProviderHandler calculates the taxes of a cart.
+type TaxProviderHandler func(ctx context.Context, tax TaxProviderRequest, api *APIClient) (TaxProviderResponse, error)
+
+type TaxProviderRequest struct {
+	*AppRequest
+
+	Cart    Cart                `json:"cart"`
+	Context SalesChannelContext `json:"context"`
+}
+
+// TaxProviderResponse contains the calculated taxes. Taxes of line items and deliveries, which are not contained, are
+// kept as calculated by Shopware.
+type TaxProviderResponse struct {
+	// LineItemTaxes are keyed by the ID of the line item.
+	LineItemTaxes map[string][]CalculatedTax `json:"lineItemTaxes,omitempty"`
+	// DeliveryTaxes are keyed by the identifier of the delivery position.
+	DeliveryTaxes map[string][]CalculatedTax `json:"deliveryTaxes,omitempty"`
+	// CartPriceTaxes replace the taxes of the whole cart.
+	CartPriceTaxes []CalculatedTax `json:"cartPriceTaxes,omitempty"`
+}
+
+// TaxProvider registers a tax provider by the identifier used in the manifest.xml.
+func (srv *Server) TaxProvider(identifier string, handler TaxProviderHandler) {
+	srv.taxProviders[identifier] = handler
+}
+
+// HandleTaxProvider verifies and handles a tax calculation request of Shopware. Shopware doesn't send the identifier
+// of the tax provider, so it has to be taken from the URL configured in the manifest.xml. The returned response must
+// be written with SignedResponse.Write.
+func (srv *Server) HandleTaxProvider(req *http.Request, identifier string) (SignedResponse, error) {
+	h, ok := srv.taxProviders[identifier]
+	if !ok {
+		return SignedResponse{}, TaxProviderNotFoundError{identifier: identifier}
+	}
+
+	body, credentials, secret, err := srv.readSignedRequest(req)
+	if err != nil {
+		return SignedResponse{}, err
+	}
+
+	taxReq := TaxProviderRequest{}
+	if err := json.Unmarshal(body, &taxReq); err != nil {
+		return SignedResponse{}, fmt.Errorf("parse body: %w", err)
+	}
+
+	inv := Invocation{
+		Type:   InvocationTypeTaxProvider,
+		Name:   identifier,
+		Source: taxReq.Source,
+		API:    srv.newAPIClient(credentials),
+	}
+
+	return srv.invokeSigned(req.Context(), inv, secret, func(ctx context.Context, api *APIClient) (interface{}, error) {
+		return h(ctx, taxReq, api)
+	})
+}
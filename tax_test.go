@@ -0,0 +1,54 @@
+package appserver_test
+
+import (
+	"context"
+	"testing"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleTaxProvider(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	srv.TaxProvider("myTaxProvider", func(_ context.Context, tax appserver.TaxProviderRequest, _ *appserver.APIClient) (appserver.TaxProviderResponse, error) {
+		assert.Equal(t, "DE", tax.Context.ShippingLocation.Country.Iso)
+
+		resp := appserver.TaxProviderResponse{
+			LineItemTaxes: map[string][]appserver.CalculatedTax{},
+			DeliveryTaxes: map[string][]appserver.CalculatedTax{},
+		}
+
+		for _, lineItem := range tax.Cart.LineItems {
+			resp.LineItemTaxes[lineItem.ID] = []appserver.CalculatedTax{{Tax: 19, TaxRate: 19, Price: lineItem.Price.TotalPrice}}
+		}
+
+		for _, delivery := range tax.Cart.Deliveries {
+			for _, position := range delivery.Positions {
+				resp.DeliveryTaxes[position.Identifier] = []appserver.CalculatedTax{{Tax: 0.95, TaxRate: 19, Price: 5}}
+			}
+		}
+
+		resp.CartPriceTaxes = []appserver.CalculatedTax{{Tax: 19.95, TaxRate: 19, Price: 105}}
+
+		return resp, nil
+	})
+
+	payload := `{"source":{"shopId":"123"},"context":{"shippingLocation":{"country":{"iso":"DE"}}},` +
+		`"cart":{"lineItems":[{"id":"l1","price":{"totalPrice":100}}],"deliveries":[{"positions":[{"identifier":"l1"}]}]}}`
+
+	resp, err := srv.HandleTaxProvider(appserver.NewSignedTestRequest("mysecret", payload), "myTaxProvider")
+	require.NoError(t, err)
+	assertSignedResponse(t, resp, "mysecret", `{
+		"lineItemTaxes":{"l1":[{"tax":19,"taxRate":19,"price":100}]},
+		"deliveryTaxes":{"l1":[{"tax":0.95,"taxRate":19,"price":5}]},
+		"cartPriceTaxes":[{"tax":19.95,"taxRate":19,"price":105}]
+	}`)
+
+	_, err = srv.HandleTaxProvider(appserver.NewSignedTestRequest("mysecret", payload), "unknown")
+	assert.ErrorAs(t, err, &appserver.TaxProviderNotFoundError{})
+
+	_, err = srv.HandleTaxProvider(appserver.NewSignedTestRequest("othersecret", payload), "myTaxProvider")
+	assert.EqualError(t, err, "invalid signature")
+}
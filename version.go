@@ -0,0 +1,132 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// VersionHandler makes changes to an entity version. All requests sent through api operate on the version.
+type VersionHandler func(ctx context.Context, api *APIClient) error
+
+// CreateVersion creates a new version of an entity and returns its version ID. Changes made with the version ID
+// header, see RequestVersionID, are not visible in the live version until the version is merged.
+func (c *APIClient) CreateVersion(ctx context.Context, entity string, id string) (string, error) {
+	resp, err := c.Request(ctx, http.MethodPost, "/api/_action/version/"+url.PathEscape(entity)+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	version := struct {
+		VersionID string `json:"versionId"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", fmt.Errorf("parse body: %w", err)
+	}
+
+	return version.VersionID, nil
+}
+
+// MergeVersion merges all changes of the version into the live version of the entity.
+func (c *APIClient) MergeVersion(ctx context.Context, entity string, versionID string) error {
+	path := "/api/_action/version/merge/" + url.PathEscape(entity) + "/" + url.PathEscape(versionID)
+
+	resp, err := c.Request(ctx, http.MethodPost, path, nil, RequestVersionID(versionID))
+	if err != nil {
+		return err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// DeleteVersion discards the version of the entity with all its changes.
+func (c *APIClient) DeleteVersion(ctx context.Context, entity string, id string, versionID string) error {
+	path := "/api/_action/version/" + url.PathEscape(versionID) + "/" + url.PathEscape(entity) + "/" + url.PathEscape(id)
+
+	resp, err := c.Request(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// EditVersioned creates a new version of the entity and calls handler with a client bound to that version. If the
+// handler succeeds, the version is merged into the live version. Otherwise, or if merging fails, the version is
+// discarded.
+func (c *APIClient) EditVersioned(ctx context.Context, entity string, id string, handler VersionHandler) (err error) {
+	versionID, err := c.CreateVersion(ctx, entity, id)
+	if err != nil {
+		return fmt.Errorf("create version: %w", err)
+	}
+
+	defer func() {
+		r := recover()
+		if err == nil && r == nil {
+			return
+		}
+
+		// use a fresh context, as the original one might have been the reason for the failure
+		if deleteErr := c.DeleteVersion(context.Background(), entity, id, versionID); deleteErr != nil && err != nil {
+			err = fmt.Errorf("%w (discard version: %v)", err, deleteErr)
+		}
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	if err := handler(ctx, c.With(RequestVersionID(versionID))); err != nil {
+		return err
+	}
+
+	if err := c.MergeVersion(ctx, entity, versionID); err != nil {
+		return fmt.Errorf("merge version: %w", err)
+	}
+
+	return nil
+}
+
+// EditOrder edits an order the way the Shopware administration does: changes are made on a new version of the order,
+// which is recalculated and merged after the handler succeeds. See EditVersioned.
+func (c *APIClient) EditOrder(ctx context.Context, orderID string, handler VersionHandler) error {
+	return c.EditVersioned(ctx, "order", orderID, func(ctx context.Context, api *APIClient) error {
+		if err := handler(ctx, api); err != nil {
+			return err
+		}
+
+		return api.RecalculateOrder(ctx, orderID)
+	})
+}
+
+// RecalculateOrder recalculates prices and taxes of an order. Use this on a client bound to an order version.
+func (c *APIClient) RecalculateOrder(ctx context.Context, orderID string) error {
+	resp, err := c.Request(ctx, http.MethodPost, "/api/_action/order/"+url.PathEscape(orderID)+"/recalculate", nil)
+	if err != nil {
+		return err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
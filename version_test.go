@@ -0,0 +1,60 @@
+package appserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIClient_EditOrder(t *testing.T) {
+	var calls []string
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path+" "+r.Header.Get(HeaderVersionID))
+
+		if r.URL.Path == "/api/_action/version/order/order1" {
+			_, _ = w.Write([]byte(`{"versionId":"v1"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		calls = nil
+
+		err := client.EditOrder(context.Background(), "order1", func(ctx context.Context, api *APIClient) error {
+			resp, err := api.Request(ctx, http.MethodPatch, "/api/order/order1", map[string]string{"customerComment": "foo"})
+			if err != nil {
+				return err
+			}
+
+			return resp.Body.Close()
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/api/_action/version/order/order1 ",
+			"/api/order/order1 v1",
+			"/api/_action/order/order1/recalculate v1",
+			"/api/_action/version/merge/order/v1 v1",
+		}, calls)
+	})
+
+	t.Run("discard on error", func(t *testing.T) {
+		calls = nil
+		handlerErr := errors.New("handler failed")
+
+		err := client.EditOrder(context.Background(), "order1", func(ctx context.Context, api *APIClient) error {
+			return handlerErr
+		})
+
+		assert.ErrorIs(t, err, handlerErr)
+		assert.Equal(t, []string{
+			"/api/_action/version/order/order1 ",
+			"/api/_action/version/v1/order/order1 ",
+		}, calls)
+	})
+}
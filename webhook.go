@@ -1,13 +1,19 @@
 package appserver
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
+const EventSystemConfigWritten = "system-config.written"
+
 var ErrWebhookMissingEvent = errors.New("missing event")
 
 type WebhookHandlerNotFoundError struct {
@@ -18,55 +24,267 @@ func (e WebhookHandlerNotFoundError) Error() string {
 	return fmt.Sprintf("no webhook handler found for event: %s", e.event)
 }
 
+// MultiHandlerError is returned if more than one handler of a webhook failed.
+type MultiHandlerError struct {
+	errs []error
+}
+
+func (e MultiHandlerError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Errors returns the errors of all failed handlers in the order the handlers were called.
+func (e MultiHandlerError) Errors() []error {
+	return e.errs
+}
+
+func (e MultiHandlerError) Unwrap() []error {
+	return e.errs
+}
+
+// Is reports whether any of the handler errors matches target. It lets errors.Is look into the handler errors on Go
+// versions that do not unwrap multiple errors yet.
+func (e MultiHandlerError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first handler error that matches target, see Is.
+func (e MultiHandlerError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
 type WebhookHandler func(ctx context.Context, webhook WebhookRequest, api *APIClient) error
 
 type WebhookRequest struct {
 	*AppRequest
 
-	Data struct {
-		Payload map[string]interface{} `json:"payload"`
-		Event   string                 `json:"event"`
-	} `json:"data"`
+	Data WebhookData `json:"data"`
+	// Timestamp is the unix time the webhook was dispatched by the shop.
+	Timestamp int64 `json:"timestamp"`
 }
 
-func (srv *Server) HandleWebhook(req *http.Request) error {
-	if err := srv.verifyPayloadSignature(req); err != nil {
+// Time returns the time the webhook was dispatched by the shop.
+func (r WebhookRequest) Time() time.Time {
+	return time.Unix(r.Timestamp, 0)
+}
+
+type WebhookData struct {
+	// Payload is the decoded payload, if the event sends an object. Entity written events send a list instead, use
+	// RawPayload for those.
+	Payload    map[string]interface{} `json:"-"`
+	RawPayload json.RawMessage        `json:"payload"`
+	Event      string                 `json:"event"`
+}
+
+func (d *WebhookData) UnmarshalJSON(data []byte) error {
+	type webhookData WebhookData
+
+	raw := webhookData{}
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
 
+	*d = WebhookData(raw)
+
+	if trimmed := bytes.TrimSpace(d.RawPayload); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &d.Payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (srv *Server) HandleWebhook(req *http.Request) error {
+	_, err := srv.HandleWebhookWithOutcome(req)
+
+	return err
+}
+
+// HandleWebhookWithOutcome works like HandleWebhook, but also reports how the webhook was handled, e.g. whether it
+// was skipped as a duplicate.
+func (srv *Server) HandleWebhookWithOutcome(req *http.Request) (WebhookOutcome, error) {
+	if _, err := srv.verifyPayloadSignature(req); err != nil {
+		return WebhookFailed, err
+	}
+
 	body, err := extractBody(req)
 	if err != nil {
-		return fmt.Errorf("extract body: %w", err)
+		return WebhookFailed, fmt.Errorf("extract body: %w", err)
 	}
 
 	if len(body) == 0 {
-		return errors.New("empty payload")
+		return WebhookFailed, errors.New("empty payload")
 	}
 
 	webhookReq := WebhookRequest{}
 	err = json.Unmarshal(body, &webhookReq)
 	if err != nil {
-		return fmt.Errorf("parse body: %w", err)
+		return WebhookFailed, fmt.Errorf("parse body: %w", err)
 	}
 
 	if len(webhookReq.Data.Event) == 0 {
-		return ErrWebhookMissingEvent
+		return WebhookFailed, ErrWebhookMissingEvent
+	}
+
+	webhookReq.ShopwareVersion = req.Header.Get(ShopwareVersionHeader)
+
+	if webhookReq.Data.Event == EventSystemConfigWritten && srv.configCache != nil {
+		// the entity written payload only contains the IDs of the written entries, not their config keys, so drop
+		// everything cached for the shop
+		srv.configCache.Invalidate(webhookReq.Source.ShopID)
+	}
+
+	handlers := srv.webhookHandlers(webhookReq.Data.Event)
+	if len(handlers) == 0 {
+		if webhookReq.Data.Event == EventSystemConfigWritten && srv.configCache != nil {
+			return WebhookHandled, nil
+		}
+
+		return WebhookFailed, WebhookHandlerNotFoundError{event: webhookReq.Data.Event}
+	}
+
+	deliveryID := ""
+	if srv.seenStore != nil {
+		deliveryID = webhookDeliveryID(webhookReq)
+	}
+
+	if deliveryID != "" {
+		seen, err := srv.seenStore.MarkSeen(req.Context(), deliveryID, srv.seenTTL)
+		if err != nil {
+			return WebhookFailed, fmt.Errorf("mark webhook as seen: %w", err)
+		}
+
+		if seen {
+			return WebhookDuplicate, nil
+		}
 	}
 
-	h, ok := srv.webhooks[webhookReq.Data.Event]
-	if !ok {
+	outcome := WebhookHandled
+	switch {
+	case srv.webhookQueue != nil:
+		outcome = WebhookQueued
+		err = srv.enqueueWebhook(req.Context(), webhookReq, body)
+	case srv.webhookPool != nil:
+		outcome = WebhookQueued
+		err = srv.webhookPool.Enqueue(webhookReq)
+	default:
+		err = srv.dispatchWebhook(req.Context(), webhookReq)
+	}
+
+	if err != nil {
+		if deliveryID != "" {
+			// allow Shopware to redeliver the webhook
+			if forgetErr := srv.seenStore.Forget(req.Context(), deliveryID); forgetErr != nil {
+				err = fmt.Errorf("%w (forget webhook: %v)", err, forgetErr)
+			}
+		}
+
+		return WebhookFailed, err
+	}
+
+	return outcome, nil
+}
+
+// dispatchWebhook calls all handlers of the verified webhook.
+func (srv *Server) dispatchWebhook(ctx context.Context, webhookReq WebhookRequest) error {
+	handlers := srv.webhookHandlers(webhookReq.Data.Event)
+	if len(handlers) == 0 {
 		return WebhookHandlerNotFoundError{event: webhookReq.Data.Event}
 	}
 
-	credentials, err := srv.credentialStore.Get(req.Context(), webhookReq.Source.ShopID)
+	credentials, err := srv.credentialStore.Get(ctx, webhookReq.Source.ShopID)
 	if err != nil {
 		return fmt.Errorf("get shop credentials: %w", err)
 	}
 
-	err = h(req.Context(), webhookReq, newAPIClient(srv.httpClient, srv.appName, credentials, srv.tokenStore))
-	if err != nil {
-		return fmt.Errorf("handler: %w", err)
+	inv := Invocation{
+		Type:   InvocationTypeWebhook,
+		Name:   webhookReq.Data.Event,
+		Source: webhookReq.Source,
+		API:    srv.newAPIClient(credentials),
 	}
 
-	return nil
+	// middlewares and the timeout wrap the whole dispatch, all handlers are called, even if one of them fails
+	return srv.invoke(ctx, inv, func(ctx context.Context, inv Invocation) error {
+		var errs []error
+		for _, h := range handlers {
+			h := h
+			err := recoverHandler(ctx, inv, func(ctx context.Context, inv Invocation) error {
+				return h(ctx, webhookReq, inv.API)
+			})
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		switch len(errs) {
+		case 0:
+			return nil
+		case 1:
+			return fmt.Errorf("handler: %w", errs[0])
+		default:
+			return fmt.Errorf("handler: %w", MultiHandlerError{errs: errs})
+		}
+	})
+}
+
+// webhookHandlers returns all handlers matching the event in the order they should be called, see Server.Event.
+func (srv *Server) webhookHandlers(event string) []WebhookHandler {
+	handlers := append([]WebhookHandler{}, srv.webhooks[event]...)
+
+	patterns := make([]string, 0)
+	for pattern := range srv.webhooks {
+		if pattern != event && matchEventPattern(pattern, event) {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	// more specific patterns first, sort by name for a stable order of equally specific ones
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		handlers = append(handlers, srv.webhooks[pattern]...)
+	}
+
+	if len(handlers) == 0 && srv.webhookFallback != nil {
+		handlers = append(handlers, srv.webhookFallback)
+	}
+
+	return handlers
+}
+
+func matchEventPattern(pattern string, event string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	if !strings.HasSuffix(pattern, ".*") {
+		return false
+	}
+
+	return strings.HasPrefix(event, strings.TrimSuffix(pattern, "*"))
 }
@@ -0,0 +1,161 @@
+package appserver
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+var (
+	ErrWebhookQueueFull   = errors.New("webhook queue is full")
+	ErrWebhookQueueClosed = errors.New("webhook queue is closed")
+)
+
+// AsyncConfig configures the asynchronous processing of webhooks, see WithAsyncWebhooks.
+type AsyncConfig struct {
+	// Workers is the number of webhooks processed concurrently. Defaults to the number of CPUs.
+	Workers int
+	// QueueSize is the number of webhooks, which can wait for processing, shared equally by all workers. Defaults to
+	// 100 per worker.
+	QueueSize int
+	// OnError is called with the error of failed handlers, since it can't be returned to Shopware anymore.
+	OnError func(webhook WebhookRequest, err error)
+}
+
+// WithAsyncWebhooks processes webhooks in a pool of background workers. HandleWebhook verifies and queues the webhook
+// and returns immediately, so Shopware gets a response before the handlers are called. If the queue is full,
+// HandleWebhook returns ErrWebhookQueueFull, which should be answered with status 503 Service Unavailable.
+//
+// Webhooks of the same shop are always processed by the same worker, in the order they were received. Call
+// Server.Shutdown to process the remaining webhooks before the application exits.
+func WithAsyncWebhooks(cfg AsyncConfig) ServerOpt {
+	return func(s *Server) {
+		s.asyncConfig = &cfg
+	}
+}
+
+// Shutdown stops accepting webhooks and waits until all queued webhooks are processed. If the context expires first,
+// the contexts of the running handlers are canceled and the context's error is returned.
+//
+// With WithWebhookQueue, only the webhooks currently being processed are awaited, the remaining ones stay in the queue.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	switch {
+	case srv.queueWorkers != nil:
+		return srv.queueWorkers.Shutdown(ctx)
+	case srv.webhookPool != nil:
+		return srv.webhookPool.Shutdown(ctx)
+	default:
+		return nil
+	}
+}
+
+type webhookPool struct {
+	queues  []chan WebhookRequest
+	process func(ctx context.Context, webhook WebhookRequest) error
+	onError func(webhook WebhookRequest, err error)
+
+	// ctx is passed to the handlers and canceled, if the shutdown deadline is exceeded
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	closed   bool
+	closedMu sync.RWMutex
+}
+
+func newWebhookPool(cfg AsyncConfig, process func(ctx context.Context, webhook WebhookRequest) error) *webhookPool {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	queueSize := cfg.QueueSize / workers
+	if cfg.QueueSize <= 0 {
+		queueSize = 100
+	}
+
+	if queueSize == 0 {
+		queueSize = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool := &webhookPool{
+		queues:  make([]chan WebhookRequest, workers),
+		process: process,
+		onError: cfg.OnError,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	for i := range pool.queues {
+		pool.queues[i] = make(chan WebhookRequest, queueSize)
+
+		pool.wg.Add(1)
+		go pool.work(pool.queues[i])
+	}
+
+	return pool
+}
+
+func (p *webhookPool) Enqueue(webhook WebhookRequest) error {
+	p.closedMu.RLock()
+	defer p.closedMu.RUnlock()
+
+	if p.closed {
+		return ErrWebhookQueueClosed
+	}
+
+	select {
+	case p.queues[p.shard(webhook.Source.ShopID)] <- webhook:
+		return nil
+	default:
+		return ErrWebhookQueueFull
+	}
+}
+
+func (p *webhookPool) Shutdown(ctx context.Context) error {
+	p.closedMu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	}
+	p.closedMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *webhookPool) work(queue chan WebhookRequest) {
+	defer p.wg.Done()
+
+	for webhook := range queue {
+		if err := p.process(p.ctx, webhook); err != nil && p.onError != nil {
+			p.onError(webhook, err)
+		}
+	}
+}
+
+// shard assigns all webhooks of a shop to the same worker to keep them in order.
+func (p *webhookPool) shard(shopID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(shopID))
+
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
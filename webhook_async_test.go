@@ -0,0 +1,121 @@
+package appserver_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_AsyncWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var processed []string
+	var failed []error
+
+	srv := newWebhookTestServer(t, appserver.WithAsyncWebhooks(appserver.AsyncConfig{
+		Workers:   4,
+		QueueSize: 100,
+		OnError: func(_ appserver.WebhookRequest, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, err)
+		},
+	}))
+
+	appserver.EventTyped(srv, "product.written", func(_ context.Context, _ appserver.WebhookRequest, payload appserver.EntityWrittenPayload, _ *appserver.APIClient) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, payload[0].PrimaryKey.ID)
+
+		if payload[0].PrimaryKey.ID == "fail" {
+			return errors.New("failed")
+		}
+
+		return nil
+	})
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("%d", i)
+		expected = append(expected, id)
+
+		payload := `{"data":{"event":"product.written","payload":[{"primaryKey":"` + id + `"}]},"source":{"shopId":"123"}}`
+		require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+	}
+
+	payload := `{"data":{"event":"product.written","payload":[{"primaryKey":"fail"}]},"source":{"shopId":"123"}}`
+	require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+	expected = append(expected, "fail")
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	// webhooks of the same shop are processed in order
+	assert.Equal(t, expected, processed)
+	if assert.Len(t, failed, 1) {
+		assert.EqualError(t, failed[0], "handler: failed")
+	}
+
+	err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+	assert.ErrorIs(t, err, appserver.ErrWebhookQueueClosed)
+}
+
+func TestServer_AsyncWebhooksQueueFull(t *testing.T) {
+	srv := newWebhookTestServer(t, appserver.WithAsyncWebhooks(appserver.AsyncConfig{
+		Workers:   1,
+		QueueSize: 1,
+	}))
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv.Event("product.written", func(ctx context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		started <- struct{}{}
+		<-release
+
+		return nil
+	})
+
+	payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`
+
+	// first webhook blocks the worker, second one waits in the queue
+	require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+	<-started
+	require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+
+	err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+	assert.ErrorIs(t, err, appserver.ErrWebhookQueueFull)
+
+	close(release)
+	require.NoError(t, srv.Shutdown(context.Background()))
+}
+
+func TestServer_AsyncWebhooksShutdownTimeout(t *testing.T) {
+	srv := newWebhookTestServer(t, appserver.WithAsyncWebhooks(appserver.AsyncConfig{Workers: 1}))
+
+	canceled := make(chan struct{})
+	srv.Event("product.written", func(ctx context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		<-ctx.Done()
+		close(canceled)
+
+		return ctx.Err()
+	})
+
+	payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`
+	require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, srv.Shutdown(ctx), context.DeadlineExceeded)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not canceled")
+	}
+}
@@ -0,0 +1,128 @@
+package appserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WebhookOutcome describes how HandleWebhookWithOutcome handled a webhook.
+type WebhookOutcome int
+
+const (
+	// WebhookFailed is returned together with an error.
+	WebhookFailed WebhookOutcome = iota
+	// WebhookHandled means all handlers were called successfully.
+	WebhookHandled
+	// WebhookQueued means the webhook is processed asynchronously.
+	WebhookQueued
+	// WebhookDuplicate means the webhook was delivered before and was skipped.
+	WebhookDuplicate
+)
+
+func (o WebhookOutcome) String() string {
+	switch o {
+	case WebhookFailed:
+		return "failed"
+	case WebhookHandled:
+		return "handled"
+	case WebhookQueued:
+		return "queued"
+	case WebhookDuplicate:
+		return "duplicate"
+	default:
+		return "unknown"
+	}
+}
+
+// WebhookSeenStore remembers delivered webhooks to skip duplicates, see WithWebhookDeduplication.
+type WebhookSeenStore interface {
+	// MarkSeen marks the key as seen for the given duration and reports whether it was already seen before.
+	MarkSeen(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Forget removes the key, e.g. if processing the webhook failed and Shopware should be able to redeliver it.
+	Forget(ctx context.Context, key string) error
+}
+
+// WithWebhookDeduplication skips webhooks, which were delivered before within the ttl. Webhooks are identified by
+// the event ID sent by Shopware, or by their timestamp and payload for older Shopware versions. Webhooks without
+// both are never skipped, because separate events with the same payload can't be told apart from a redelivery. If
+// the handlers fail, the webhook is forgotten, so a redelivery is processed again.
+func WithWebhookDeduplication(store WebhookSeenStore, ttl time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.seenStore = store
+		s.seenTTL = ttl
+	}
+}
+
+// webhookDeliveryID identifies a webhook delivery across retries. It returns an empty ID, if the webhook has neither
+// an event ID nor a timestamp.
+func webhookDeliveryID(webhookReq WebhookRequest) string {
+	if webhookReq.Source.EventID != "" {
+		return webhookReq.Source.ShopID + ":" + webhookReq.Source.EventID
+	}
+
+	if webhookReq.Timestamp == 0 {
+		return ""
+	}
+
+	h := sha256.New()
+	h.Write([]byte(webhookReq.Data.Event))
+	h.Write(webhookReq.Data.RawPayload)
+
+	return webhookReq.Source.ShopID + ":" + strconv.FormatInt(webhookReq.Timestamp, 10) + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+var _ WebhookSeenStore = (*MemoryWebhookSeenStore)(nil)
+
+// MemoryWebhookSeenStore keeps seen webhooks in memory. Expired keys are removed periodically while marking new ones.
+type MemoryWebhookSeenStore struct {
+	expiresAt   map[string]time.Time
+	lastCleanup time.Time
+	mu          sync.Mutex
+
+	now func() time.Time
+}
+
+func NewMemoryWebhookSeenStore() *MemoryWebhookSeenStore {
+	return &MemoryWebhookSeenStore{
+		expiresAt: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryWebhookSeenStore) MarkSeen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	if now.Sub(s.lastCleanup) > time.Minute {
+		for k, expiresAt := range s.expiresAt {
+			if !expiresAt.After(now) {
+				delete(s.expiresAt, k)
+			}
+		}
+
+		s.lastCleanup = now
+	}
+
+	if expiresAt, ok := s.expiresAt[key]; ok && expiresAt.After(now) {
+		return true, nil
+	}
+
+	s.expiresAt[key] = now.Add(ttl)
+
+	return false, nil
+}
+
+func (s *MemoryWebhookSeenStore) Forget(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.expiresAt, key)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package appserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WebhookDeduplication(t *testing.T) {
+	srv := newWebhookTestServer(t, appserver.WithWebhookDeduplication(appserver.NewMemoryWebhookSeenStore(), time.Hour))
+
+	calls := 0
+	var handlerErr error
+	srv.Event("product.written", func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		calls++
+		return handlerErr
+	})
+
+	t.Run("event id", func(t *testing.T) {
+		calls = 0
+		payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123","eventId":"e1"},"timestamp":1}`
+
+		outcome, err := srv.HandleWebhookWithOutcome(appserver.NewSignedTestRequest("mysecret", payload))
+		require.NoError(t, err)
+		assert.Equal(t, appserver.WebhookHandled, outcome)
+
+		outcome, err = srv.HandleWebhookWithOutcome(appserver.NewSignedTestRequest("mysecret", payload))
+		require.NoError(t, err)
+		assert.Equal(t, appserver.WebhookDuplicate, outcome)
+
+		// same event ID, but different timestamp on redelivery
+		payload = `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123","eventId":"e1"},"timestamp":2}`
+		outcome, err = srv.HandleWebhookWithOutcome(appserver.NewSignedTestRequest("mysecret", payload))
+		require.NoError(t, err)
+		assert.Equal(t, appserver.WebhookDuplicate, outcome)
+
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("timestamp and payload", func(t *testing.T) {
+		calls = 0
+		payload := `{"data":{"event":"product.written","payload":[{"primaryKey":"a"}]},"source":{"shopId":"123"},"timestamp":1}`
+
+		require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+		require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+
+		payload = `{"data":{"event":"product.written","payload":[{"primaryKey":"b"}]},"source":{"shopId":"123"},"timestamp":1}`
+		require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("without event id and timestamp", func(t *testing.T) {
+		calls = 0
+		payload := `{"data":{"event":"product.written","payload":[{"primaryKey":"a"}]},"source":{"shopId":"123"}}`
+
+		// e.g. the same product updated twice
+		for i := 0; i < 2; i++ {
+			outcome, err := srv.HandleWebhookWithOutcome(appserver.NewSignedTestRequest("mysecret", payload))
+			require.NoError(t, err)
+			assert.Equal(t, appserver.WebhookHandled, outcome)
+		}
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("failed webhooks are processed again", func(t *testing.T) {
+		calls = 0
+		handlerErr = errors.New("failed")
+		payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123","eventId":"e2"}}`
+
+		outcome, err := srv.HandleWebhookWithOutcome(appserver.NewSignedTestRequest("mysecret", payload))
+		assert.Error(t, err)
+		assert.Equal(t, appserver.WebhookFailed, outcome)
+
+		handlerErr = nil
+		outcome, err = srv.HandleWebhookWithOutcome(appserver.NewSignedTestRequest("mysecret", payload))
+		require.NoError(t, err)
+		assert.Equal(t, appserver.WebhookHandled, outcome)
+
+		assert.Equal(t, 2, calls)
+	})
+}
@@ -0,0 +1,158 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	EventOrderPlaced      = "checkout.order.placed"
+	EventCustomerRegister = "checkout.customer.register"
+	EventCustomerLogin    = "checkout.customer.login"
+	EventCustomerLogout   = "checkout.customer.logout"
+	EventProductWritten   = "product.written"
+	EventProductDeleted   = "product.deleted"
+
+	EntityOperationInsert = "insert"
+	EntityOperationUpdate = "update"
+	EntityOperationDelete = "delete"
+)
+
+// TypedWebhookHandler is a webhook handler, which receives the payload decoded into T.
+type TypedWebhookHandler[T any] func(ctx context.Context, webhook WebhookRequest, payload T, api *APIClient) error
+
+// EventTyped registers a handler for an event, which receives the payload decoded into T. Use the payload types of
+// this package, e.g. EntityWrittenPayload or OrderPlacedPayload, or your own structs.
+func EventTyped[T any](srv *Server, event string, handler TypedWebhookHandler[T]) {
+	srv.Event(event, func(ctx context.Context, webhook WebhookRequest, api *APIClient) error {
+		var payload T
+		if err := webhook.DecodePayload(&payload); err != nil {
+			return err
+		}
+
+		return handler(ctx, webhook, payload, api)
+	})
+}
+
+// DecodePayload decodes the payload of the webhook into v.
+func (r WebhookRequest) DecodePayload(v interface{}) error {
+	if err := json.Unmarshal(r.Data.RawPayload, v); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+
+	return nil
+}
+
+// EntityWrittenPayload is sent with entity written and deleted events, e.g. product.written.
+type EntityWrittenPayload []EntityWrittenResult
+
+type EntityWrittenResult struct {
+	Entity        string           `json:"entity"`
+	Operation     string           `json:"operation"`
+	PrimaryKey    EntityPrimaryKey `json:"primaryKey"`
+	UpdatedFields []string         `json:"updatedFields"`
+	VersionID     string           `json:"versionId"`
+}
+
+// EntityPrimaryKey is the primary key of a written entity. Most entities are identified by a single ID, mapping
+// entities use a combination of fields instead.
+type EntityPrimaryKey struct {
+	ID     string
+	Fields map[string]string
+}
+
+func (k *EntityPrimaryKey) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &k.ID); err == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, &k.Fields)
+}
+
+func (k EntityPrimaryKey) MarshalJSON() ([]byte, error) {
+	if k.Fields != nil {
+		return json.Marshal(k.Fields)
+	}
+
+	return json.Marshal(k.ID)
+}
+
+// OrderPlacedPayload is sent with the checkout.order.placed event.
+type OrderPlacedPayload struct {
+	Order Order `json:"order"`
+}
+
+// CustomerPayload is sent with customer events, e.g. checkout.customer.register or checkout.customer.login.
+type CustomerPayload struct {
+	Customer     Customer `json:"customer"`
+	ContextToken string   `json:"contextToken,omitempty"`
+}
+
+type Order struct {
+	ID                string                 `json:"id"`
+	VersionID         string                 `json:"versionId"`
+	OrderNumber       string                 `json:"orderNumber"`
+	OrderDateTime     time.Time              `json:"orderDateTime"`
+	SalesChannelID    string                 `json:"salesChannelId"`
+	LanguageID        string                 `json:"languageId"`
+	CurrencyID        string                 `json:"currencyId"`
+	CurrencyFactor    float64                `json:"currencyFactor"`
+	AmountTotal       float64                `json:"amountTotal"`
+	AmountNet         float64                `json:"amountNet"`
+	PositionPrice     float64                `json:"positionPrice"`
+	ShippingTotal     float64                `json:"shippingTotal"`
+	TaxStatus         string                 `json:"taxStatus"`
+	CustomerComment   string                 `json:"customerComment"`
+	StateMachineState *StateMachineState     `json:"stateMachineState"`
+	OrderCustomer     *OrderCustomer         `json:"orderCustomer"`
+	LineItems         []OrderLineItem        `json:"lineItems"`
+	Transactions      []OrderTransaction     `json:"transactions"`
+	CustomFields      map[string]interface{} `json:"customFields"`
+}
+
+type OrderCustomer struct {
+	ID             string `json:"id"`
+	CustomerID     string `json:"customerId"`
+	CustomerNumber string `json:"customerNumber"`
+	Email          string `json:"email"`
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	Company        string `json:"company"`
+}
+
+type OrderLineItem struct {
+	ID           string                 `json:"id"`
+	Identifier   string                 `json:"identifier"`
+	ReferencedID string                 `json:"referencedId"`
+	ProductID    string                 `json:"productId"`
+	Type         string                 `json:"type"`
+	Label        string                 `json:"label"`
+	Quantity     int                    `json:"quantity"`
+	UnitPrice    float64                `json:"unitPrice"`
+	TotalPrice   float64                `json:"totalPrice"`
+	Position     int                    `json:"position"`
+	Payload      map[string]interface{} `json:"payload"`
+}
+
+type Customer struct {
+	ID             string                 `json:"id"`
+	CustomerNumber string                 `json:"customerNumber"`
+	Email          string                 `json:"email"`
+	FirstName      string                 `json:"firstName"`
+	LastName       string                 `json:"lastName"`
+	Company        string                 `json:"company"`
+	GroupID        string                 `json:"groupId"`
+	SalesChannelID string                 `json:"salesChannelId"`
+	LanguageID     string                 `json:"languageId"`
+	Active         bool                   `json:"active"`
+	Guest          bool                   `json:"guest"`
+	CustomFields   map[string]interface{} `json:"customFields"`
+}
+
+type StateMachineState struct {
+	ID            string `json:"id"`
+	TechnicalName string `json:"technicalName"`
+	Name          string `json:"name"`
+}
@@ -0,0 +1,255 @@
+package appserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrWebhookQueueEmpty = errors.New("no webhook available")
+
+// WebhookQueue durably stores verified webhooks until they're processed, see WithWebhookQueue.
+type WebhookQueue interface {
+	// Enqueue stores a new webhook.
+	Enqueue(ctx context.Context, webhook QueuedWebhook) error
+	// Dequeue returns the oldest webhook available for processing and locks it for the lease duration. Only the oldest
+	// webhook of each shop is handed out, so the webhooks of a shop are processed one at a time and in order, even if
+	// the oldest one waits for a retry. Returns ErrWebhookQueueEmpty, if no webhook is available.
+	Dequeue(ctx context.Context, lease time.Duration) (QueuedWebhook, error)
+	// Complete removes a processed webhook.
+	Complete(ctx context.Context, id string) error
+	// Retry unlocks a failed webhook and stores its attempts, last error and the time it's available again.
+	Retry(ctx context.Context, webhook QueuedWebhook) error
+	// Bury moves a webhook, which failed too often, to the dead letters.
+	Bury(ctx context.Context, webhook QueuedWebhook) error
+	// DeadLetters returns all buried webhooks.
+	DeadLetters(ctx context.Context) ([]QueuedWebhook, error)
+	// Replay moves a dead letter back to the queue and resets its attempts.
+	Replay(ctx context.Context, id string) error
+}
+
+// QueuedWebhook is a verified webhook stored in a WebhookQueue.
+type QueuedWebhook struct {
+	ID     string
+	ShopID string
+	Event  string
+	// Payload is the raw body of the webhook request.
+	Payload []byte
+	// ShopwareVersion is the version header of the webhook request, which isn't part of the payload.
+	ShopwareVersion string
+	Attempts        int
+	LastError       string
+	CreatedAt       time.Time
+	AvailableAt     time.Time
+}
+
+// QueueConfig configures the processing of webhooks from a WebhookQueue.
+type QueueConfig struct {
+	// Workers is the number of webhooks processed concurrently. Defaults to 1.
+	Workers int
+	// PollInterval is the time to wait for new webhooks, if the queue is empty. Defaults to 1 second.
+	PollInterval time.Duration
+	// Lease is the time a webhook is locked for processing. If the process dies, the webhook is available again after
+	// this time. Defaults to 5 minutes.
+	Lease time.Duration
+	// MaxAttempts is the number of times a webhook is processed, before it's moved to the dead letters. Defaults to 5.
+	MaxAttempts int
+	// Backoff returns the delay before the given attempt is retried. Defaults to an exponential backoff, which waits
+	// 2 seconds before the first retry and doubles with every attempt, capped at 1 hour.
+	Backoff func(attempts int) time.Duration
+	// OnError is called with the error of every failed attempt.
+	OnError func(webhook QueuedWebhook, err error)
+}
+
+// WithWebhookQueue stores verified webhooks in the queue and processes them in background workers, so they survive
+// restarts of the process. Failed webhooks are retried with backoff and moved to the dead letters of the queue after
+// QueueConfig.MaxAttempts. This replaces WithAsyncWebhooks. Call Server.Shutdown to stop the workers.
+func WithWebhookQueue(queue WebhookQueue, cfg QueueConfig) ServerOpt {
+	return func(s *Server) {
+		s.webhookQueue = queue
+		s.queueConfig = cfg
+	}
+}
+
+func (srv *Server) enqueueWebhook(ctx context.Context, webhookReq WebhookRequest, body []byte) error {
+	id, err := newID()
+	if err != nil {
+		return fmt.Errorf("generate webhook id: %w", err)
+	}
+
+	now := time.Now()
+
+	err = srv.webhookQueue.Enqueue(ctx, QueuedWebhook{
+		ID:              id,
+		ShopID:          webhookReq.Source.ShopID,
+		Event:           webhookReq.Data.Event,
+		Payload:         body,
+		ShopwareVersion: webhookReq.ShopwareVersion,
+		CreatedAt:       now,
+		AvailableAt:     now,
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue webhook: %w", err)
+	}
+
+	return nil
+}
+
+func defaultQueueBackoff(attempts int) time.Duration {
+	if attempts > 12 {
+		return time.Hour
+	}
+
+	backoff := time.Second << attempts
+	if backoff > time.Hour {
+		return time.Hour
+	}
+
+	return backoff
+}
+
+type webhookQueueWorkers struct {
+	queue   WebhookQueue
+	cfg     QueueConfig
+	process func(ctx context.Context, webhook WebhookRequest) error
+
+	// ctx is passed to the handlers and canceled, if the shutdown deadline is exceeded
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+func newWebhookQueueWorkers(queue WebhookQueue, cfg QueueConfig, process func(ctx context.Context, webhook WebhookRequest) error) *webhookQueueWorkers {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	if cfg.Backoff == nil {
+		cfg.Backoff = defaultQueueBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &webhookQueueWorkers{
+		queue:   queue,
+		cfg:     cfg,
+		process: process,
+		ctx:     ctx,
+		cancel:  cancel,
+		stop:    make(chan struct{}),
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		w.wg.Add(1)
+		go w.work()
+	}
+
+	return w
+}
+
+func (w *webhookQueueWorkers) Shutdown(ctx context.Context) error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+}
+
+func (w *webhookQueueWorkers) work() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		webhook, err := w.queue.Dequeue(w.ctx, w.cfg.Lease)
+		if err != nil {
+			if !errors.Is(err, ErrWebhookQueueEmpty) && w.cfg.OnError != nil {
+				w.cfg.OnError(webhook, fmt.Errorf("dequeue: %w", err))
+			}
+
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(w.cfg.PollInterval):
+			}
+
+			continue
+		}
+
+		w.handle(webhook)
+	}
+}
+
+func (w *webhookQueueWorkers) handle(webhook QueuedWebhook) {
+	err := w.processQueued(webhook)
+	if err == nil {
+		if err := w.queue.Complete(w.ctx, webhook.ID); err != nil && w.cfg.OnError != nil {
+			w.cfg.OnError(webhook, fmt.Errorf("complete: %w", err))
+		}
+
+		return
+	}
+
+	if w.cfg.OnError != nil {
+		w.cfg.OnError(webhook, err)
+	}
+
+	webhook.Attempts++
+	webhook.LastError = err.Error()
+
+	if webhook.Attempts >= w.cfg.MaxAttempts {
+		err = w.queue.Bury(w.ctx, webhook)
+	} else {
+		webhook.AvailableAt = time.Now().Add(w.cfg.Backoff(webhook.Attempts))
+		err = w.queue.Retry(w.ctx, webhook)
+	}
+
+	if err != nil && w.cfg.OnError != nil {
+		w.cfg.OnError(webhook, fmt.Errorf("reschedule: %w", err))
+	}
+}
+
+func (w *webhookQueueWorkers) processQueued(webhook QueuedWebhook) error {
+	webhookReq := WebhookRequest{}
+	if err := json.Unmarshal(webhook.Payload, &webhookReq); err != nil {
+		return fmt.Errorf("parse body: %w", err)
+	}
+
+	webhookReq.ShopwareVersion = webhook.ShopwareVersion
+
+	return w.process(w.ctx, webhookReq)
+}
@@ -0,0 +1,168 @@
+package appserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	_ WebhookQueue = (*MemoryWebhookQueue)(nil)
+
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// MemoryWebhookQueue keeps webhooks in memory. Webhooks are lost when the process exits, so it should only be used
+// for development and tests.
+type MemoryWebhookQueue struct {
+	webhooks    []QueuedWebhook
+	deadLetters []QueuedWebhook
+	lockedUntil map[string]time.Time
+	mu          sync.Mutex
+
+	now func() time.Time
+}
+
+func NewMemoryWebhookQueue() *MemoryWebhookQueue {
+	return &MemoryWebhookQueue{
+		lockedUntil: make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+func (q *MemoryWebhookQueue) Enqueue(ctx context.Context, webhook QueuedWebhook) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.insert(webhook)
+
+	return nil
+}
+
+func (q *MemoryWebhookQueue) Dequeue(ctx context.Context, lease time.Duration) (QueuedWebhook, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+
+	// only the oldest webhook of a shop may be processed, to keep the order of its webhooks
+	seenShops := make(map[string]bool)
+	for _, webhook := range q.webhooks {
+		if seenShops[webhook.ShopID] {
+			continue
+		}
+
+		seenShops[webhook.ShopID] = true
+
+		if q.isLocked(webhook.ID, now) || webhook.AvailableAt.After(now) {
+			continue
+		}
+
+		q.lockedUntil[webhook.ID] = now.Add(lease)
+
+		return webhook, nil
+	}
+
+	return QueuedWebhook{}, ErrWebhookQueueEmpty
+}
+
+func (q *MemoryWebhookQueue) Complete(ctx context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.remove(id) {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (q *MemoryWebhookQueue) Retry(ctx context.Context, webhook QueuedWebhook) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.webhooks {
+		if q.webhooks[i].ID == webhook.ID {
+			q.webhooks[i] = webhook
+			delete(q.lockedUntil, webhook.ID)
+
+			return nil
+		}
+	}
+
+	return ErrWebhookNotFound
+}
+
+func (q *MemoryWebhookQueue) Bury(ctx context.Context, webhook QueuedWebhook) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.remove(webhook.ID) {
+		return ErrWebhookNotFound
+	}
+
+	q.deadLetters = append(q.deadLetters, webhook)
+
+	return nil
+}
+
+func (q *MemoryWebhookQueue) DeadLetters(ctx context.Context) ([]QueuedWebhook, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]QueuedWebhook{}, q.deadLetters...), nil
+}
+
+func (q *MemoryWebhookQueue) Replay(ctx context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, webhook := range q.deadLetters {
+		if webhook.ID != id {
+			continue
+		}
+
+		q.deadLetters = append(q.deadLetters[:i], q.deadLetters[i+1:]...)
+
+		webhook.Attempts = 0
+		webhook.LastError = ""
+		webhook.AvailableAt = q.now()
+		q.insert(webhook)
+
+		return nil
+	}
+
+	return ErrWebhookNotFound
+}
+
+func (q *MemoryWebhookQueue) isLocked(id string, now time.Time) bool {
+	lockedUntil, ok := q.lockedUntil[id]
+
+	return ok && lockedUntil.After(now)
+}
+
+// insert adds the webhook ordered by its creation time, so replayed webhooks keep their position.
+func (q *MemoryWebhookQueue) insert(webhook QueuedWebhook) {
+	i := len(q.webhooks)
+	for i > 0 && q.webhooks[i-1].CreatedAt.After(webhook.CreatedAt) {
+		i--
+	}
+
+	q.webhooks = append(q.webhooks, QueuedWebhook{})
+	copy(q.webhooks[i+1:], q.webhooks[i:])
+	q.webhooks[i] = webhook
+}
+
+func (q *MemoryWebhookQueue) remove(id string) bool {
+	for i, webhook := range q.webhooks {
+		if webhook.ID == id {
+			q.webhooks = append(q.webhooks[:i], q.webhooks[i+1:]...)
+			delete(q.lockedUntil, id)
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package appserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryWebhookQueue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	queue := NewMemoryWebhookQueue()
+	queue.now = func() time.Time { return now }
+
+	require.NoError(t, queue.Enqueue(ctx, QueuedWebhook{ID: "a1", ShopID: "shopA", AvailableAt: now}))
+	require.NoError(t, queue.Enqueue(ctx, QueuedWebhook{ID: "a2", ShopID: "shopA", AvailableAt: now}))
+	require.NoError(t, queue.Enqueue(ctx, QueuedWebhook{ID: "b1", ShopID: "shopB", AvailableAt: now}))
+
+	webhook, err := queue.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a1", webhook.ID)
+
+	// shop A is busy until a1 is done
+	webhook, err = queue.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "b1", webhook.ID)
+
+	_, err = queue.Dequeue(ctx, time.Minute)
+	assert.ErrorIs(t, err, ErrWebhookQueueEmpty)
+
+	// retry a1 later, a2 waits for it to keep the order of shop A
+	require.NoError(t, queue.Retry(ctx, QueuedWebhook{ID: "a1", ShopID: "shopA", Attempts: 1, AvailableAt: now.Add(time.Minute)}))
+	_, err = queue.Dequeue(ctx, time.Minute)
+	assert.ErrorIs(t, err, ErrWebhookQueueEmpty)
+
+	// lease of b1 expires
+	now = now.Add(2 * time.Minute)
+	webhook, err = queue.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a1", webhook.ID)
+	assert.Equal(t, 1, webhook.Attempts)
+
+	webhook, err = queue.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "b1", webhook.ID)
+
+	require.NoError(t, queue.Complete(ctx, "a1"))
+	a2, err := queue.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a2", a2.ID)
+	require.NoError(t, queue.Complete(ctx, "a2"))
+
+	// dead letters
+	webhook.LastError = "failed"
+	require.NoError(t, queue.Bury(ctx, webhook))
+	deadLetters, err := queue.DeadLetters(ctx)
+	require.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, "failed", deadLetters[0].LastError)
+	}
+
+	require.NoError(t, queue.Replay(ctx, "b1"))
+	deadLetters, err = queue.DeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Len(t, deadLetters, 0)
+
+	webhook, err = queue.Dequeue(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "b1", webhook.ID)
+	assert.Equal(t, "", webhook.LastError)
+
+	assert.ErrorIs(t, queue.Complete(ctx, "unknown"), ErrWebhookNotFound)
+	assert.ErrorIs(t, queue.Replay(ctx, "unknown"), ErrWebhookNotFound)
+}
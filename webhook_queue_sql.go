@@ -0,0 +1,238 @@
+package appserver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ WebhookQueue = (*SQLWebhookQueue)(nil)
+
+// SQLWebhookQueue stores webhooks in a SQL database. The queries use LIMIT, so they work with MySQL, MariaDB,
+// PostgreSQL and SQLite, but not with SQL Server or Oracle. The table has to be created beforehand, e.g. for MySQL:
+//
+//	CREATE TABLE webhook_queue (
+//	    id               VARCHAR(32)  NOT NULL PRIMARY KEY,
+//	    shop_id          VARCHAR(255) NOT NULL,
+//	    event            VARCHAR(255) NOT NULL,
+//	    payload          MEDIUMBLOB   NOT NULL,
+//	    shopware_version VARCHAR(255) NOT NULL DEFAULT '',
+//	    attempts         INT          NOT NULL DEFAULT 0,
+//	    last_error       TEXT         NOT NULL,
+//	    created_at       BIGINT       NOT NULL,
+//	    available_at     BIGINT       NOT NULL,
+//	    locked_until     BIGINT       NOT NULL DEFAULT 0,
+//	    dead             SMALLINT     NOT NULL DEFAULT 0,
+//	    INDEX idx_webhook_queue_available (dead, available_at, created_at),
+//	    INDEX idx_webhook_queue_shop (shop_id, dead, created_at)
+//	);
+//
+// Tables created by earlier versions need the new column:
+//
+//	ALTER TABLE webhook_queue ADD shopware_version VARCHAR(255) NOT NULL DEFAULT '';
+//
+// Timestamps are stored as unix nanoseconds. Only the oldest webhook of a shop is handed out and locked with a
+// conditional update, so the webhooks of a shop are processed in order, even by multiple processes.
+type SQLWebhookQueue struct {
+	db    *sql.DB
+	table string
+	// dollarPlaceholders uses $1, $2, ... instead of ?, as required by PostgreSQL
+	dollarPlaceholders bool
+
+	now func() time.Time
+}
+
+type SQLWebhookQueueOpt func(q *SQLWebhookQueue)
+
+// WithSQLTable sets the name of the table. Defaults to "webhook_queue".
+func WithSQLTable(table string) SQLWebhookQueueOpt {
+	return func(q *SQLWebhookQueue) {
+		q.table = table
+	}
+}
+
+// WithSQLDollarPlaceholders uses numbered placeholders ($1, $2, ...), as required by PostgreSQL.
+func WithSQLDollarPlaceholders() SQLWebhookQueueOpt {
+	return func(q *SQLWebhookQueue) {
+		q.dollarPlaceholders = true
+	}
+}
+
+func NewSQLWebhookQueue(db *sql.DB, opts ...SQLWebhookQueueOpt) *SQLWebhookQueue {
+	q := &SQLWebhookQueue{
+		db:    db,
+		table: "webhook_queue",
+		now:   time.Now,
+	}
+
+	for _, o := range opts {
+		o(q)
+	}
+
+	return q
+}
+
+func (q *SQLWebhookQueue) Enqueue(ctx context.Context, webhook QueuedWebhook) error {
+	_, err := q.db.ExecContext(ctx, q.query(
+		"INSERT INTO %s (id, shop_id, event, payload, shopware_version, attempts, last_error, created_at, available_at, "+
+			"locked_until, dead) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0)",
+	), webhook.ID, webhook.ShopID, webhook.Event, webhook.Payload, webhook.ShopwareVersion, webhook.Attempts, webhook.LastError,
+		webhook.CreatedAt.UnixNano(), webhook.AvailableAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("insert webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (q *SQLWebhookQueue) Dequeue(ctx context.Context, lease time.Duration) (QueuedWebhook, error) {
+	now := q.now().UnixNano()
+
+	// Another process might lock the same webhook between select and update. In that case, the update doesn't
+	// affect any row and the next webhook is tried.
+	for i := 0; i < 3; i++ {
+		row := q.db.QueryRowContext(ctx, q.query(
+			"SELECT id, shop_id, event, payload, shopware_version, attempts, last_error, created_at, available_at FROM %[1]s w "+
+				"WHERE dead = 0 AND available_at <= ? AND locked_until <= ? "+
+				"AND NOT EXISTS (SELECT 1 FROM %[1]s o WHERE o.shop_id = w.shop_id AND o.dead = 0 "+
+				"AND (o.created_at < w.created_at OR (o.created_at = w.created_at AND o.id < w.id))) "+
+				"ORDER BY created_at, id LIMIT 1",
+		), now, now)
+
+		webhook, err := scanQueuedWebhook(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return QueuedWebhook{}, ErrWebhookQueueEmpty
+		}
+
+		if err != nil {
+			return QueuedWebhook{}, fmt.Errorf("select webhook: %w", err)
+		}
+
+		res, err := q.db.ExecContext(ctx, q.query(
+			"UPDATE %s SET locked_until = ? WHERE id = ? AND dead = 0 AND locked_until <= ?",
+		), now+int64(lease), webhook.ID, now)
+		if err != nil {
+			return QueuedWebhook{}, fmt.Errorf("lock webhook: %w", err)
+		}
+
+		if affected, err := res.RowsAffected(); err == nil && affected == 1 {
+			return webhook, nil
+		}
+	}
+
+	return QueuedWebhook{}, ErrWebhookQueueEmpty
+}
+
+func (q *SQLWebhookQueue) Complete(ctx context.Context, id string) error {
+	return q.exec(ctx, "DELETE FROM %s WHERE id = ? AND dead = 0", id)
+}
+
+func (q *SQLWebhookQueue) Retry(ctx context.Context, webhook QueuedWebhook) error {
+	return q.exec(ctx,
+		"UPDATE %s SET attempts = ?, last_error = ?, available_at = ?, locked_until = 0 WHERE id = ? AND dead = 0",
+		webhook.Attempts, webhook.LastError, webhook.AvailableAt.UnixNano(), webhook.ID,
+	)
+}
+
+func (q *SQLWebhookQueue) Bury(ctx context.Context, webhook QueuedWebhook) error {
+	return q.exec(ctx,
+		"UPDATE %s SET attempts = ?, last_error = ?, locked_until = 0, dead = 1 WHERE id = ? AND dead = 0",
+		webhook.Attempts, webhook.LastError, webhook.ID,
+	)
+}
+
+func (q *SQLWebhookQueue) DeadLetters(ctx context.Context) ([]QueuedWebhook, error) {
+	rows, err := q.db.QueryContext(ctx, q.query(
+		"SELECT id, shop_id, event, payload, shopware_version, attempts, last_error, created_at, available_at FROM %s "+
+			"WHERE dead = 1 ORDER BY created_at, id",
+	))
+	if err != nil {
+		return nil, fmt.Errorf("select dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []QueuedWebhook
+	for rows.Next() {
+		webhook, err := scanQueuedWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan dead letter: %w", err)
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (q *SQLWebhookQueue) Replay(ctx context.Context, id string) error {
+	return q.exec(ctx,
+		"UPDATE %s SET attempts = 0, last_error = '', available_at = ?, locked_until = 0, dead = 0 WHERE id = ? AND dead = 1",
+		q.now().UnixNano(), id,
+	)
+}
+
+// exec runs a statement, which must affect exactly one webhook.
+func (q *SQLWebhookQueue) exec(ctx context.Context, query string, args ...interface{}) error {
+	res, err := q.db.ExecContext(ctx, q.query(query), args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// query inserts the table name and converts the placeholders, if needed.
+func (q *SQLWebhookQueue) query(query string) string {
+	query = fmt.Sprintf(query, q.table)
+
+	if !q.dollarPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanQueuedWebhook(row rowScanner) (QueuedWebhook, error) {
+	webhook := QueuedWebhook{}
+	var createdAt, availableAt int64
+
+	err := row.Scan(&webhook.ID, &webhook.ShopID, &webhook.Event, &webhook.Payload, &webhook.ShopwareVersion,
+		&webhook.Attempts, &webhook.LastError, &createdAt, &availableAt)
+	if err != nil {
+		return QueuedWebhook{}, err
+	}
+
+	webhook.CreatedAt = time.Unix(0, createdAt)
+	webhook.AvailableAt = time.Unix(0, availableAt)
+
+	return webhook, nil
+}
@@ -0,0 +1,344 @@
+package appserver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLWebhookQueue_query(t *testing.T) {
+	q := NewSQLWebhookQueue(nil)
+	assert.Equal(t, "DELETE FROM webhook_queue WHERE id = ? AND dead = 0", q.query("DELETE FROM %s WHERE id = ? AND dead = 0"))
+
+	q = NewSQLWebhookQueue(nil, WithSQLTable("app_webhooks"), WithSQLDollarPlaceholders())
+	assert.Equal(t,
+		"SELECT id FROM app_webhooks WHERE locked_until <= $1 AND shop_id NOT IN (SELECT shop_id FROM app_webhooks WHERE locked_until > $2)",
+		q.query("SELECT id FROM %[1]s WHERE locked_until <= ? AND shop_id NOT IN (SELECT shop_id FROM %[1]s WHERE locked_until > ?)"),
+	)
+}
+
+func TestSQLWebhookQueue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	lease := time.Minute
+
+	webhookRow := func(id string, shopID string, attempts int64, lastError string) []driver.Value {
+		return []driver.Value{id, shopID, "product.written", []byte(`{}`), "6.6.0.0", attempts, lastError, now.UnixNano(), now.UnixNano()}
+	}
+
+	const (
+		selectQuery = "SELECT id, shop_id, event, payload, shopware_version, attempts, last_error, created_at, available_at FROM webhook_queue w " +
+			"WHERE dead = 0 AND available_at <= ? AND locked_until <= ? " +
+			"AND NOT EXISTS (SELECT 1 FROM webhook_queue o WHERE o.shop_id = w.shop_id AND o.dead = 0 " +
+			"AND (o.created_at < w.created_at OR (o.created_at = w.created_at AND o.id < w.id))) " +
+			"ORDER BY created_at, id LIMIT 1"
+		lockQuery   = "UPDATE webhook_queue SET locked_until = ? WHERE id = ? AND dead = 0 AND locked_until <= ?"
+		retryQuery  = "UPDATE webhook_queue SET attempts = ?, last_error = ?, available_at = ?, locked_until = 0 WHERE id = ? AND dead = 0"
+		buryQuery   = "UPDATE webhook_queue SET attempts = ?, last_error = ?, locked_until = 0, dead = 1 WHERE id = ? AND dead = 0"
+		replayQuery = "UPDATE webhook_queue SET attempts = 0, last_error = '', available_at = ?, locked_until = 0, dead = 0 WHERE id = ? AND dead = 1"
+		deleteQuery = "DELETE FROM webhook_queue WHERE id = ? AND dead = 0"
+	)
+
+	t.Run("enqueue", func(t *testing.T) {
+		db := newFakeSQLDB(t, fakeSQLExchange{
+			query:    "INSERT INTO webhook_queue (id, shop_id, event, payload, shopware_version, attempts, last_error, created_at, available_at, locked_until, dead) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0)",
+			args:     []driver.Value{"a1", "shopA", "product.written", []byte(`{}`), "6.6.0.0", int64(0), "", now.UnixNano(), now.UnixNano()},
+			affected: 1,
+		})
+
+		q := NewSQLWebhookQueue(db)
+		require.NoError(t, q.Enqueue(ctx, QueuedWebhook{
+			ID:              "a1",
+			ShopID:          "shopA",
+			Event:           "product.written",
+			Payload:         []byte(`{}`),
+			ShopwareVersion: "6.6.0.0",
+			CreatedAt:       now,
+			AvailableAt:     now,
+		}))
+	})
+
+	t.Run("dequeue locks the oldest webhook of a shop", func(t *testing.T) {
+		db := newFakeSQLDB(t,
+			fakeSQLExchange{
+				query: selectQuery,
+				args:  []driver.Value{now.UnixNano(), now.UnixNano()},
+				rows:  [][]driver.Value{webhookRow("a1", "shopA", 1, "failed")},
+			},
+			fakeSQLExchange{
+				query:    lockQuery,
+				args:     []driver.Value{now.Add(lease).UnixNano(), "a1", now.UnixNano()},
+				affected: 1,
+			},
+		)
+
+		q := NewSQLWebhookQueue(db)
+		q.now = func() time.Time { return now }
+
+		webhook, err := q.Dequeue(ctx, lease)
+		require.NoError(t, err)
+		assert.Equal(t, QueuedWebhook{
+			ID:              "a1",
+			ShopID:          "shopA",
+			Event:           "product.written",
+			Payload:         []byte(`{}`),
+			ShopwareVersion: "6.6.0.0",
+			Attempts:        1,
+			LastError:       "failed",
+			CreatedAt:       now,
+			AvailableAt:     now,
+		}, webhook)
+	})
+
+	t.Run("dequeue tries again if another process locked the webhook", func(t *testing.T) {
+		db := newFakeSQLDB(t,
+			fakeSQLExchange{query: selectQuery, rows: [][]driver.Value{webhookRow("a1", "shopA", 0, "")}},
+			fakeSQLExchange{query: lockQuery, affected: 0},
+			fakeSQLExchange{query: selectQuery, rows: [][]driver.Value{webhookRow("b1", "shopB", 0, "")}},
+			fakeSQLExchange{query: lockQuery, affected: 1},
+		)
+
+		q := NewSQLWebhookQueue(db)
+		q.now = func() time.Time { return now }
+
+		webhook, err := q.Dequeue(ctx, lease)
+		require.NoError(t, err)
+		assert.Equal(t, "b1", webhook.ID)
+	})
+
+	t.Run("dequeue empty queue", func(t *testing.T) {
+		db := newFakeSQLDB(t, fakeSQLExchange{query: selectQuery})
+
+		_, err := NewSQLWebhookQueue(db).Dequeue(ctx, lease)
+		assert.ErrorIs(t, err, ErrWebhookQueueEmpty)
+	})
+
+	t.Run("dequeue error", func(t *testing.T) {
+		errDB := errors.New("connection lost")
+		db := newFakeSQLDB(t, fakeSQLExchange{query: selectQuery, err: errDB})
+
+		_, err := NewSQLWebhookQueue(db).Dequeue(ctx, lease)
+		assert.ErrorIs(t, err, errDB)
+		assert.ErrorContains(t, err, "select webhook")
+	})
+
+	t.Run("dequeue with dollar placeholders", func(t *testing.T) {
+		db := newFakeSQLDB(t,
+			fakeSQLExchange{
+				query: "SELECT id, shop_id, event, payload, shopware_version, attempts, last_error, created_at, available_at FROM app_webhooks w " +
+					"WHERE dead = 0 AND available_at <= $1 AND locked_until <= $2 " +
+					"AND NOT EXISTS (SELECT 1 FROM app_webhooks o WHERE o.shop_id = w.shop_id AND o.dead = 0 " +
+					"AND (o.created_at < w.created_at OR (o.created_at = w.created_at AND o.id < w.id))) " +
+					"ORDER BY created_at, id LIMIT 1",
+				rows: [][]driver.Value{webhookRow("a1", "shopA", 0, "")},
+			},
+			fakeSQLExchange{
+				query:    "UPDATE app_webhooks SET locked_until = $1 WHERE id = $2 AND dead = 0 AND locked_until <= $3",
+				affected: 1,
+			},
+		)
+
+		_, err := NewSQLWebhookQueue(db, WithSQLTable("app_webhooks"), WithSQLDollarPlaceholders()).Dequeue(ctx, lease)
+		require.NoError(t, err)
+	})
+
+	t.Run("retry, bury and replay", func(t *testing.T) {
+		db := newFakeSQLDB(t,
+			fakeSQLExchange{
+				query:    retryQuery,
+				args:     []driver.Value{int64(1), "failed", now.Add(time.Minute).UnixNano(), "a1"},
+				affected: 1,
+			},
+			fakeSQLExchange{
+				query:    buryQuery,
+				args:     []driver.Value{int64(5), "failed", "a1"},
+				affected: 1,
+			},
+			fakeSQLExchange{
+				query: "SELECT id, shop_id, event, payload, shopware_version, attempts, last_error, created_at, available_at FROM webhook_queue WHERE dead = 1 ORDER BY created_at, id",
+				rows:  [][]driver.Value{webhookRow("a1", "shopA", 5, "failed"), webhookRow("b1", "shopB", 5, "failed")},
+			},
+			fakeSQLExchange{
+				query:    replayQuery,
+				args:     []driver.Value{now.UnixNano(), "a1"},
+				affected: 1,
+			},
+			fakeSQLExchange{
+				query:    deleteQuery,
+				args:     []driver.Value{"a1"},
+				affected: 1,
+			},
+		)
+
+		q := NewSQLWebhookQueue(db)
+		q.now = func() time.Time { return now }
+
+		webhook := QueuedWebhook{ID: "a1", ShopID: "shopA", Attempts: 1, LastError: "failed", AvailableAt: now.Add(time.Minute)}
+		require.NoError(t, q.Retry(ctx, webhook))
+
+		webhook.Attempts = 5
+		require.NoError(t, q.Bury(ctx, webhook))
+
+		deadLetters, err := q.DeadLetters(ctx)
+		require.NoError(t, err)
+		if assert.Len(t, deadLetters, 2) {
+			assert.Equal(t, "a1", deadLetters[0].ID)
+			assert.Equal(t, 5, deadLetters[0].Attempts)
+			assert.Equal(t, "b1", deadLetters[1].ID)
+		}
+
+		require.NoError(t, q.Replay(ctx, "a1"))
+		require.NoError(t, q.Complete(ctx, "a1"))
+	})
+
+	t.Run("unknown webhook", func(t *testing.T) {
+		db := newFakeSQLDB(t,
+			fakeSQLExchange{query: retryQuery, affected: 0},
+			fakeSQLExchange{query: buryQuery, affected: 0},
+			fakeSQLExchange{query: replayQuery, affected: 0},
+			fakeSQLExchange{query: deleteQuery, affected: 0},
+		)
+
+		q := NewSQLWebhookQueue(db)
+		assert.ErrorIs(t, q.Retry(ctx, QueuedWebhook{ID: "unknown"}), ErrWebhookNotFound)
+		assert.ErrorIs(t, q.Bury(ctx, QueuedWebhook{ID: "unknown"}), ErrWebhookNotFound)
+		assert.ErrorIs(t, q.Replay(ctx, "unknown"), ErrWebhookNotFound)
+		assert.ErrorIs(t, q.Complete(ctx, "unknown"), ErrWebhookNotFound)
+	})
+}
+
+// fakeSQLExchange is a statement the fake database expects and its result. Args are only compared if set. The fake
+// doesn't run the statements, so it checks the statements and the handling of their results, not the SQL itself.
+type fakeSQLExchange struct {
+	query    string
+	args     []driver.Value
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// fakeSQLConnector is a database/sql driver, which replays the expected statements in order.
+type fakeSQLConnector struct {
+	t         *testing.T
+	mu        sync.Mutex
+	exchanges []fakeSQLExchange
+}
+
+func newFakeSQLDB(t *testing.T, exchanges ...fakeSQLExchange) *sql.DB {
+	t.Helper()
+
+	c := &fakeSQLConnector{t: t, exchanges: exchanges}
+	db := sql.OpenDB(c)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+		assert.Empty(t, c.exchanges, "expected statements were not executed")
+	})
+
+	return db
+}
+
+func (c *fakeSQLConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeSQLConn{connector: c}, nil
+}
+
+func (c *fakeSQLConnector) Driver() driver.Driver {
+	return fakeSQLDriver{}
+}
+
+func (c *fakeSQLConnector) next(query string, args []driver.NamedValue) (fakeSQLExchange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.exchanges) == 0 {
+		c.t.Errorf("unexpected statement: %s", query)
+		return fakeSQLExchange{}, errors.New("unexpected statement")
+	}
+
+	exchange := c.exchanges[0]
+	c.exchanges = c.exchanges[1:]
+
+	assert.Equal(c.t, exchange.query, query)
+
+	if exchange.args != nil {
+		values := make([]driver.Value, 0, len(args))
+		for _, arg := range args {
+			values = append(values, arg.Value)
+		}
+
+		assert.Equal(c.t, exchange.args, values, "arguments of %q", query)
+	}
+
+	return exchange, exchange.err
+}
+
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("use sql.OpenDB")
+}
+
+type fakeSQLConn struct {
+	connector *fakeSQLConnector
+}
+
+func (c *fakeSQLConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	exchange, err := c.connector.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(exchange.affected), nil
+}
+
+func (c *fakeSQLConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	exchange, err := c.connector.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeSQLRows{rows: exchange.rows}, nil
+}
+
+func (c *fakeSQLConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeSQLConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeSQLRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string {
+	return []string{"id", "shop_id", "event", "payload", "shopware_version", "attempts", "last_error", "created_at", "available_at"}
+}
+
+func (r *fakeSQLRows) Close() error {
+	return nil
+}
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package appserver_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_WebhookQueue(t *testing.T) {
+	queue := appserver.NewMemoryWebhookQueue()
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	done := make(chan struct{}, 10)
+
+	srv := newWebhookTestServer(t, appserver.WithWebhookQueue(queue, appserver.QueueConfig{
+		Workers:      2,
+		PollInterval: time.Millisecond,
+		MaxAttempts:  3,
+		Backoff: func(int) time.Duration {
+			return 0
+		},
+	}))
+	defer func() {
+		require.NoError(t, srv.Shutdown(context.Background()))
+	}()
+
+	appserver.EventTyped(srv, "product.written", func(_ context.Context, _ appserver.WebhookRequest, payload appserver.EntityWrittenPayload, _ *appserver.APIClient) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id := payload[0].PrimaryKey.ID
+		attempts[id]++
+		defer func() { done <- struct{}{} }()
+
+		if id == "flaky" && attempts[id] < 2 {
+			return errors.New("temporary")
+		}
+
+		if id == "broken" {
+			return errors.New("permanent")
+		}
+
+		return nil
+	})
+
+	for _, id := range []string{"ok", "flaky", "broken"} {
+		payload := `{"data":{"event":"product.written","payload":[{"primaryKey":"` + id + `"}]},"source":{"shopId":"123"}}`
+		require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+	}
+
+	// ok: 1, flaky: 2, broken: 3 attempts
+	for i := 0; i < 6; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhooks were not processed")
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		deadLetters, err := queue.DeadLetters(context.Background())
+		return err == nil && len(deadLetters) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, map[string]int{"ok": 1, "flaky": 2, "broken": 3}, attempts)
+	mu.Unlock()
+
+	deadLetters, err := queue.DeadLetters(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "product.written", deadLetters[0].Event)
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+	assert.Equal(t, "handler: permanent", deadLetters[0].LastError)
+
+	// replaying processes the webhook again
+	require.NoError(t, queue.Replay(context.Background(), deadLetters[0].ID))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("replayed webhook was not processed")
+	}
+}
+
+func TestServer_WebhookQueueShopwareVersion(t *testing.T) {
+	received := make(chan appserver.WebhookRequest, 1)
+
+	srv := newWebhookTestServer(t, appserver.WithWebhookQueue(appserver.NewMemoryWebhookQueue(), appserver.QueueConfig{
+		PollInterval: time.Millisecond,
+	}))
+	defer func() {
+		require.NoError(t, srv.Shutdown(context.Background()))
+	}()
+
+	srv.Event("product.written", func(_ context.Context, webhook appserver.WebhookRequest, _ *appserver.APIClient) error {
+		received <- webhook
+		return nil
+	})
+
+	req := appserver.NewSignedTestRequest("mysecret", `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`)
+	req.Header.Set(appserver.ShopwareVersionHeader, "6.6.0.0")
+	require.NoError(t, srv.HandleWebhook(req))
+
+	select {
+	case webhook := <-received:
+		assert.Equal(t, "6.6.0.0", webhook.ShopwareVersion)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not processed")
+	}
+}
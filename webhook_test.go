@@ -0,0 +1,144 @@
+package appserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appserver "github.com/janbuecker/shopware-appserver-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCredentialStore returns a store with the shop "123" and the shop secret "mysecret".
+func newTestCredentialStore(t *testing.T) appserver.CredentialStore {
+	t.Helper()
+
+	store := appserver.NewMemoryCredentialStore()
+	require.NoError(t, store.Store(context.Background(), appserver.Credentials{
+		ShopID:     "123",
+		ShopURL:    "https://shop.example",
+		ShopSecret: "mysecret",
+	}))
+
+	return store
+}
+
+func newWebhookTestServer(t *testing.T, opts ...appserver.ServerOpt) *appserver.Server {
+	t.Helper()
+
+	return appserver.NewServer("", "mysecret", "", append([]appserver.ServerOpt{appserver.WithCredentialStore(newTestCredentialStore(t))}, opts...)...)
+}
+
+func TestEventTyped(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	t.Run("entity written", func(t *testing.T) {
+		var received appserver.EntityWrittenPayload
+		appserver.EventTyped(srv, appserver.EventProductWritten, func(_ context.Context, _ appserver.WebhookRequest, payload appserver.EntityWrittenPayload, _ *appserver.APIClient) error {
+			received = payload
+			return nil
+		})
+
+		payload := `{"data":{"event":"product.written","payload":[` +
+			`{"entity":"product","operation":"update","primaryKey":"abc","updatedFields":["stock"],"versionId":"v1"},` +
+			`{"entity":"product_category","operation":"insert","primaryKey":{"productId":"abc","categoryId":"def"},"updatedFields":[]}` +
+			`]},"source":{"shopId":"123"}}`
+
+		require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+		require.Len(t, received, 2)
+		assert.Equal(t, "product", received[0].Entity)
+		assert.Equal(t, appserver.EntityOperationUpdate, received[0].Operation)
+		assert.Equal(t, "abc", received[0].PrimaryKey.ID)
+		assert.Equal(t, []string{"stock"}, received[0].UpdatedFields)
+		assert.Equal(t, "v1", received[0].VersionID)
+		assert.Equal(t, map[string]string{"productId": "abc", "categoryId": "def"}, received[1].PrimaryKey.Fields)
+	})
+
+	t.Run("order placed", func(t *testing.T) {
+		var received appserver.OrderPlacedPayload
+		appserver.EventTyped(srv, appserver.EventOrderPlaced, func(_ context.Context, webhook appserver.WebhookRequest, payload appserver.OrderPlacedPayload, _ *appserver.APIClient) error {
+			received = payload
+			assert.Equal(t, "10001", webhook.Data.Payload["order"].(map[string]interface{})["orderNumber"])
+			return nil
+		})
+
+		payload := `{"data":{"event":"checkout.order.placed","payload":{"order":{"id":"o1","orderNumber":"10001",` +
+			`"orderDateTime":"2023-02-01T10:11:12.000+00:00","amountTotal":19.99,"orderCustomer":{"email":"test@example.com"},` +
+			`"lineItems":[{"id":"l1","label":"Product","quantity":2}]}}},"source":{"shopId":"123"}}`
+
+		require.NoError(t, srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload)))
+		assert.Equal(t, "o1", received.Order.ID)
+		assert.Equal(t, 19.99, received.Order.AmountTotal)
+		assert.Equal(t, 2023, received.Order.OrderDateTime.Year())
+		assert.Equal(t, "test@example.com", received.Order.OrderCustomer.Email)
+		assert.Equal(t, 2, received.Order.LineItems[0].Quantity)
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		appserver.EventTyped(srv, appserver.EventCustomerLogin, func(_ context.Context, _ appserver.WebhookRequest, _ appserver.CustomerPayload, _ *appserver.APIClient) error {
+			return nil
+		})
+
+		payload := `{"data":{"event":"checkout.customer.login","payload":{"customer":"foo"}},"source":{"shopId":"123"}}`
+
+		err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+		assert.ErrorContains(t, err, "decode payload")
+	})
+}
+
+func TestServer_EventMultipleHandlers(t *testing.T) {
+	srv := newWebhookTestServer(t)
+
+	var calls []string
+	register := func(pattern string, name string, err error) {
+		srv.Event(pattern, func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+
+	errFirst := errors.New("first failed")
+	errWildcard := errors.New("wildcard failed")
+
+	register("*", "all", nil)
+	register("product.*", "product wildcard", errWildcard)
+	register("product.written", "first", errFirst)
+	register("product.written", "second", nil)
+	register("order.*", "order wildcard", nil)
+
+	payload := `{"data":{"event":"product.written","payload":[]},"source":{"shopId":"123"}}`
+	err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+
+	assert.Equal(t, []string{"first", "second", "product wildcard", "all"}, calls)
+
+	var multiErr appserver.MultiHandlerError
+	if assert.ErrorAs(t, err, &multiErr) {
+		assert.Equal(t, []error{errFirst, errWildcard}, multiErr.Errors())
+	}
+	assert.ErrorIs(t, err, errFirst)
+	assert.ErrorIs(t, err, errWildcard)
+	assert.EqualError(t, err, "handler: first failed; wildcard failed")
+}
+
+func TestServer_EventFallback(t *testing.T) {
+	srv := newWebhookTestServer(t)
+	srv.Event("product.*", func(_ context.Context, _ appserver.WebhookRequest, _ *appserver.APIClient) error {
+		return nil
+	})
+
+	payload := `{"data":{"event":"checkout.order.placed","payload":{}},"source":{"shopId":"123"}}`
+
+	err := srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+	assert.ErrorAs(t, err, &appserver.WebhookHandlerNotFoundError{})
+
+	var event string
+	srv.EventFallback(func(_ context.Context, webhook appserver.WebhookRequest, _ *appserver.APIClient) error {
+		event = webhook.Data.Event
+		return nil
+	})
+
+	err = srv.HandleWebhook(appserver.NewSignedTestRequest("mysecret", payload))
+	assert.NoError(t, err)
+	assert.Equal(t, "checkout.order.placed", event)
+}